@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+
+	"tms-downloader/tiles"
+)
+
+// checkAssertions downloads the asserted tiles and compares
+// them byte for byte to their reference files. Returns the
+// number of failed assertions.
+func checkAssertions(assertions tiles.Assertions) int {
+	failed := 0
+	for _, assertion := range assertions {
+		if err := checkAssertion(assertion); err != nil {
+			fmt.Printf("FAIL %v: %v\n", assertion.Tile, err)
+			failed++
+		} else {
+			fmt.Printf("ok   %v\n", assertion.Tile)
+		}
+	}
+	return failed
+}
+
+func checkAssertion(assertion tiles.Assertion) error {
+	tileID, err := tiles.ParseTileID(assertion.Tile)
+	if err != nil {
+		return err
+	}
+	reference, err := ioutil.ReadFile(assertion.Reference)
+	if err != nil {
+		return err
+	}
+	tile, err := tiles.Get(context.Background(), tileID, options)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(tile.Content, reference) {
+		return fmt.Errorf("content differs from %v (sha256 %x, %v bytes, expected sha256 %x, %v bytes)",
+			assertion.Reference,
+			sha256.Sum256(tile.Content), len(tile.Content),
+			sha256.Sum256(reference), len(reference),
+		)
+	}
+	return nil
+}
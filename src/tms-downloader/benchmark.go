@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"tms-downloader/mercantile"
+	"tms-downloader/tiles"
+)
+
+// Concurrency levels tried by benchmark.
+var benchmarkLevels = []int{1, 2, 4, 8, 16}
+
+// benchmark downloads the same random sample of tiles at several
+// concurrency levels, without saving them, and prints the throughput
+// of each level. Wait time between downloads is not applied.
+func benchmark(tileIDs []mercantile.TileID, sample int) {
+	picked := make([]mercantile.TileID, len(tileIDs))
+	copy(picked, tileIDs)
+	rand.Shuffle(len(picked), func(i, j int) {
+		picked[i], picked[j] = picked[j], picked[i]
+	})
+	if sample < len(picked) {
+		picked = picked[:sample]
+	}
+
+	fmt.Printf("%-12v %-10v %-10v %v\n", "Concurrency", "Succeeded", "Failed", "Tiles/s")
+	best, bestRate := 0, 0.0
+	for _, level := range benchmarkLevels {
+		succeeded, failed, elapsed := benchmarkLevel(picked, level)
+		rate := float64(succeeded) / elapsed.Seconds()
+		fmt.Printf("%-12v %-10v %-10v %.1f\n", level, succeeded, failed, rate)
+		if rate > bestRate {
+			best, bestRate = level, rate
+		}
+	}
+	if best > 0 {
+		fmt.Printf("Best throughput with concurrency %v\n", best)
+	}
+}
+
+// benchmarkLevel downloads the tiles with given number
+// of simultaneous requests.
+func benchmarkLevel(tileIDs []mercantile.TileID, level int) (succeeded, failed int, elapsed time.Duration) {
+	queue := make(chan mercantile.TileID)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < level; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for tileID := range queue {
+				_, err := tiles.Get(context.Background(), tiles.GetTileID(tileID.X, tileID.Y, tileID.Z), options)
+				mu.Lock()
+				if err != nil {
+					failed++
+				} else {
+					succeeded++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, tileID := range tileIDs {
+		queue <- tileID
+	}
+	close(queue)
+	wg.Wait()
+	return succeeded, failed, time.Since(start)
+}
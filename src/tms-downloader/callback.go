@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"sync"
+
+	"tms-downloader/mercantile"
+	"tms-downloader/tiles"
+)
+
+// tileCallback runs an external shell command for each saved
+// tile. At most jobs commands run at the same time. With fatal
+// the first failed command is kept as the error of the run.
+type tileCallback struct {
+	command string
+	fatal   bool
+	slots   chan struct{}
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	failed  int
+	err     error
+}
+
+func newTileCallback(command string, jobs int, fatal bool) *tileCallback {
+	if jobs < 1 {
+		jobs = 1
+	}
+	return &tileCallback{
+		command: command,
+		fatal:   fatal,
+		slots:   make(chan struct{}, jobs),
+	}
+}
+
+// Run starts the command with sh, the saved tile's path as $1
+// and TILE_Z, TILE_X, TILE_Y and TILE_PATH in the environment.
+// Blocks while all slots are busy.
+func (callback *tileCallback) Run(tile *tiles.Tile, tileID mercantile.TileID) {
+	tilePath := path.Join(tile.Path, tile.Name)
+	callback.slots <- struct{}{}
+	callback.wg.Add(1)
+	go func() {
+		defer func() {
+			<-callback.slots
+			callback.wg.Done()
+		}()
+		cmd := exec.Command("sh", "-c", callback.command, "sh", tilePath)
+		cmd.Env = append(os.Environ(),
+			fmt.Sprintf("TILE_Z=%v", tileID.Z),
+			fmt.Sprintf("TILE_X=%v", tileID.X),
+			fmt.Sprintf("TILE_Y=%v", tileID.Y),
+			fmt.Sprintf("TILE_PATH=%v", tilePath),
+		)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			logger.Warn("Tile callback failed", "path", tilePath, "error", err, "output", string(output))
+			callback.mu.Lock()
+			callback.failed++
+			if callback.fatal && callback.err == nil {
+				callback.err = fmt.Errorf("Aborting, tile callback failed for %v: %v", tilePath, err)
+			}
+			callback.mu.Unlock()
+		}
+	}()
+}
+
+// Wait waits for all started commands and returns the
+// number of failed ones.
+func (callback *tileCallback) Wait() int {
+	callback.wg.Wait()
+	callback.mu.Lock()
+	defer callback.mu.Unlock()
+	return callback.failed
+}
+
+// Err returns the error of the first failed command
+// if they are fatal, nil otherwise.
+func (callback *tileCallback) Err() error {
+	callback.mu.Lock()
+	defer callback.mu.Unlock()
+	return callback.err
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"tms-downloader/tiles"
+)
+
+// loadConfig sets the flags named in the config file (see
+// tiles.ReadConfigFile), so that its values are parsed like on the
+// command line, and options.Layers (see tiles.ReadConfigLayers).
+// Flags given on the command line take precedence: defaults < file
+// < flags. Must be called after flag.Parse.
+func loadConfig(filename string) error {
+	config, err := tiles.ReadConfigFile(filename)
+	if err != nil {
+		return err
+	}
+	if options.Layers, err = tiles.ReadConfigLayers(filename); err != nil {
+		return err
+	}
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for name, values := range config {
+		if flag.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("Config file %v: unknown option %q", filename, name)
+		}
+		if given[name] {
+			continue
+		}
+		for _, value := range values {
+			if err := flag.Set(name, value); err != nil {
+				return fmt.Errorf("Config file %v: invalid %v: %v", filename, name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"tms-downloader/mercantile"
+	"tms-downloader/tiles"
+)
+
+// checkCORS prints the result of a CORS preflight
+// request for the first tile.
+func checkCORS(tileIDs []mercantile.TileID) {
+	if len(tileIDs) == 0 {
+		log.Fatal("No tiles in the bbox")
+	}
+	tileID := tileIDs[0]
+	status, header, err := tiles.CheckCORS(tiles.GetTileID(tileID.X, tileID.Y, tileID.Z), options.CORSOrigin, options)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("OPTIONS %v/%v/%v from %v: %v %v\n", tileID.Z, tileID.X, tileID.Y, options.CORSOrigin, status, http.StatusText(status))
+	if len(header) == 0 {
+		fmt.Println("No Access-Control-* headers, tiles can not be loaded cross-origin")
+		return
+	}
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%v: %v\n", name, strings.Join(header[name], ", "))
+	}
+}
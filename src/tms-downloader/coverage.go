@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"tms-downloader/mercantile"
+)
+
+// Largest grid, in tiles per side, printed by showCoverage.
+const coverageGridMax = 100
+
+// showCoverage prints to w, for each zoom, the fraction of tiles which
+// were downloaded. With grid, present (#) and missing (.) tiles of
+// each zoom are also drawn, unless the zoom spans too many tiles.
+func showCoverage(w io.Writer, tileIDs []mercantile.TileID, present map[mercantile.TileID]bool, grid bool) {
+	byZoom := make(map[int][]mercantile.TileID)
+	for _, tileID := range tileIDs {
+		byZoom[tileID.Z] = append(byZoom[tileID.Z], tileID)
+	}
+	zooms := make([]int, 0, len(byZoom))
+	for zoom := range byZoom {
+		zooms = append(zooms, zoom)
+	}
+	sort.Ints(zooms)
+
+	fmt.Fprintln(w, "Coverage:")
+	for _, zoom := range zooms {
+		zoomTiles := byZoom[zoom]
+		count := 0
+		minX, minY, maxX, maxY := zoomTiles[0].X, zoomTiles[0].Y, zoomTiles[0].X, zoomTiles[0].Y
+		for _, tileID := range zoomTiles {
+			if present[tileID] {
+				count++
+			}
+			minX, maxX = minInt(minX, tileID.X), maxInt(maxX, tileID.X)
+			minY, maxY = minInt(minY, tileID.Y), maxInt(maxY, tileID.Y)
+		}
+		fmt.Fprintf(w, "  zoom %v: %v/%v (%.1f%%)\n", zoom, count, len(zoomTiles), 100*float64(count)/float64(len(zoomTiles)))
+
+		if !grid {
+			continue
+		}
+		if maxX-minX >= coverageGridMax || maxY-minY >= coverageGridMax {
+			fmt.Fprintf(w, "    grid too large (%vx%v tiles)\n", maxX-minX+1, maxY-minY+1)
+			continue
+		}
+		fmt.Fprintf(w, "    x %v-%v, y %v-%v\n", minX, maxX, minY, maxY)
+		for y := minY; y <= maxY; y++ {
+			var row strings.Builder
+			for x := minX; x <= maxX; x++ {
+				if present[mercantile.TileID{X: x, Y: y, Z: zoom}] {
+					row.WriteByte('#')
+				} else {
+					row.WriteByte('.')
+				}
+			}
+			fmt.Fprintf(w, "    %v\n", row.String())
+		}
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"tms-downloader/tiles"
+)
+
+// dataURIs collects tiles as data URIs keyed by "z/x/y".
+type dataURIs struct {
+	mu   sync.Mutex
+	uris map[string]string
+}
+
+func newDataURIs() *dataURIs {
+	return &dataURIs{uris: map[string]string{}}
+}
+
+// Write adds the tile. Its media type is taken from the response
+// or sniffed from the content if the server did not send one.
+func (uris *dataURIs) Write(tile *tiles.Tile) error {
+	mediaType := tile.ContentType
+	if mediaType == "" {
+		mediaType = http.DetectContentType(tile.Content)
+	}
+	key := fmt.Sprintf("%v/%v/%v", tile.TileID.Z, tile.TileID.X, tile.TileID.Y)
+	uris.mu.Lock()
+	defer uris.mu.Unlock()
+	uris.uris[key] = fmt.Sprintf("data:%v;base64,%v", mediaType, base64.StdEncoding.EncodeToString(tile.Content))
+	return nil
+}
+
+// WriteFile writes the collected tiles as a JSON object to the
+// file with the mode.
+func (uris *dataURIs) WriteFile(filename string, mode tiles.FileMode) error {
+	uris.mu.Lock()
+	defer uris.mu.Unlock()
+	content, err := json.Marshal(uris.uris)
+	if err != nil {
+		return err
+	}
+	return mode.WriteFile(filename, content)
+}
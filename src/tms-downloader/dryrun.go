@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"tms-downloader/mercantile"
+)
+
+// showTileCounts prints the number of tiles of each
+// zoom and in total, for a dry run, and the number of
+// tiles of the bbox clipped out of the area.
+func showTileCounts(tileIDs []mercantile.TileID, clipped int) {
+	counts := make(map[int]int)
+	for _, tileID := range tileIDs {
+		counts[tileID.Z]++
+	}
+	zooms := make([]int, 0, len(counts))
+	for zoom := range counts {
+		zooms = append(zooms, zoom)
+	}
+	sort.Ints(zooms)
+
+	fmt.Println("Tiles to download:")
+	for _, zoom := range zooms {
+		fmt.Printf("  zoom %v: %v\n", zoom, counts[zoom])
+	}
+	fmt.Printf("  total: %v\n", len(tileIDs))
+	if clipped > 0 {
+		fmt.Printf("Outside of the area, not downloaded: %v of %v tiles of the bbox\n", clipped, clipped+len(tileIDs))
+	}
+}
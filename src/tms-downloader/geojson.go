@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+
+	"tms-downloader/mercantile"
+)
+
+// geoJSONFeature is a single tile footprint polygon
+// with the tile coordinates as properties.
+type geoJSONFeature struct {
+	Type       string         `json:"type"`
+	Geometry   geoJSONPolygon `json:"geometry"`
+	Properties map[string]int `json:"properties"`
+}
+
+type geoJSONPolygon struct {
+	Type        string         `json:"type"`
+	Coordinates [][][2]float64 `json:"coordinates"`
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+// writeGeoJSON writes footprints of given tiles as
+// a GeoJSON FeatureCollection to the file.
+func writeGeoJSON(filename string, tileIDs []mercantile.TileID) error {
+	collection := geoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: make([]geoJSONFeature, 0, len(tileIDs)),
+	}
+	for _, tileID := range tileIDs {
+		b := mercantile.Bounds(tileID)
+		collection.Features = append(collection.Features, geoJSONFeature{
+			Type: "Feature",
+			Geometry: geoJSONPolygon{
+				Type: "Polygon",
+				Coordinates: [][][2]float64{{
+					{b.Left, b.Bottom},
+					{b.Right, b.Bottom},
+					{b.Right, b.Top},
+					{b.Left, b.Top},
+					{b.Left, b.Bottom},
+				}},
+			},
+			Properties: map[string]int{"z": tileID.Z, "x": tileID.X, "y": tileID.Y},
+		})
+	}
+	content, err := json.Marshal(collection)
+	if err != nil {
+		return err
+	}
+	return options.FileMode.WriteFile(filename, content)
+}
@@ -1,5 +1,15 @@
 module tms-downloader
 
-go 1.13
+go 1.21
 
-require github.com/Luqqk/wms-tiles-downloader v2.0.0+incompatible
+require (
+	github.com/Luqqk/wms-tiles-downloader v2.0.0+incompatible
+	github.com/gregjones/httpcache v0.0.0-20190611155906-901d90724c79
+	github.com/mattn/go-sqlite3 v1.14.52
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/google/btree v1.1.3 // indirect
+	github.com/peterbourgon/diskv v2.0.1+incompatible // indirect
+)
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"html/template"
+	"math"
+	"path"
+	"strings"
+
+	"tms-downloader/mercantile"
+)
+
+// leafletPage is a preview of the downloaded tiles, loading
+// Leaflet from a CDN.
+var leafletPage = template.Must(template.New("index.html").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Tiles</title>
+<link rel="stylesheet" href="https://unpkg.com/leaflet@1.9.4/dist/leaflet.css">
+<script src="https://unpkg.com/leaflet@1.9.4/dist/leaflet.js"></script>
+<style>html, body, #map { height: 100%; margin: 0; }</style>
+</head>
+<body>
+<div id="map"></div>
+<script>
+var bounds = L.latLngBounds([{{.Bottom}}, {{.Left}}], [{{.Top}}, {{.Right}}]);
+var map = L.map("map", {minZoom: {{.MinZoom}}, maxZoom: {{.MaxZoom}}});
+L.tileLayer({{.URL}}, {
+	tms: {{.TMS}},
+	bounds: bounds,
+	minZoom: {{.MinZoom}},
+	maxZoom: {{.MaxZoom}}
+}).addTo(map);
+map.fitBounds(bounds);
+</script>
+</body>
+</html>
+`))
+
+// leafletView is the data of leafletPage.
+type leafletView struct {
+	URL                      string
+	TMS                      bool
+	MinZoom, MaxZoom         int
+	Left, Bottom, Right, Top float64
+}
+
+// writeLeaflet writes index.html previewing the tiles saved under
+// dir as named by nameTemplate, with the bounds and zoom range of
+// the tiles.
+func writeLeaflet(dir string, tileIDs []mercantile.TileID, nameTemplate string, extension string, tms bool) error {
+	if len(tileIDs) == 0 {
+		return nil
+	}
+	view := leafletView{
+		URL:     strings.Replace(nameTemplate, "{ext}", strings.TrimPrefix(extension, "."), -1),
+		TMS:     tms,
+		MinZoom: tileIDs[0].Z,
+		MaxZoom: tileIDs[0].Z,
+		Left:    180, Bottom: 90, Right: -180, Top: -90,
+	}
+	for _, tileID := range tileIDs {
+		b := mercantile.Bounds(tileID)
+		view.Left, view.Bottom = math.Min(view.Left, b.Left), math.Min(view.Bottom, b.Bottom)
+		view.Right, view.Top = math.Max(view.Right, b.Right), math.Max(view.Top, b.Top)
+		view.MinZoom, view.MaxZoom = minInt(view.MinZoom, tileID.Z), maxInt(view.MaxZoom, tileID.Z)
+	}
+	file, err := options.FileMode.Create(path.Join(dir, "index.html"))
+	if err != nil {
+		return err
+	}
+	if err := leafletPage.Execute(file, view); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
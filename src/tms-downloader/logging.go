@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"tms-downloader/mercantile"
+	"tms-downloader/tiles"
+)
+
+// logger logs warnings of the run and, at debug level, each
+// tile. Fatal errors are still reported with the log package.
+var logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+// setLogLevel makes logger log messages of the level, one of
+// "error", "info" and "debug", and above.
+func setLogLevel(level string) error {
+	var l slog.Level
+	switch level {
+	case "error":
+		l = slog.LevelError
+	case "info", "":
+		l = slog.LevelInfo
+	case "debug":
+		l = slog.LevelDebug
+	default:
+		return fmt.Errorf("Unknown log level %q, use error, info or debug", level)
+	}
+	logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: l}))
+	return nil
+}
+
+// requested returns the URL and the response status of the
+// tile, or of the failed request if there is no tile.
+func requested(tile *tiles.Tile, err error) (string, int) {
+	var fetchErr *tiles.FetchError
+	switch {
+	case tile != nil:
+		return tile.URL, tile.StatusCode
+	case errors.As(err, &fetchErr):
+		return fetchErr.URL, fetchErr.StatusCode
+	default:
+		return "", 0
+	}
+}
+
+// logTile logs the result of downloading the tile at debug level.
+func logTile(tileID mercantile.TileID, tile *tiles.Tile, err error) {
+	name := fmt.Sprintf("%v/%v/%v", tileID.Z, tileID.X, tileID.Y)
+	if err != nil {
+		url, status := requested(tile, err)
+		logger.Debug("Tile failed", "tile", name, "url", url, "status", status, "error", err)
+		return
+	}
+	logger.Debug("Tile downloaded", "tile", name, "url", tile.URL, "status", tile.StatusCode, "bytes", len(tile.Content))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sync"
+
+	"tms-downloader/mercantile"
+	"tms-downloader/tiles"
+)
+
+// manifest records every tile attempted as a CSV line of z, x, y,
+// url, status, bytes, outcome (saved, skipped or failed) and error.
+// Lines are flushed as they are added, so an interrupted run leaves
+// a partial manifest. Tiles retried in retry passes have a line per
+// attempt, the last one being the final outcome.
+type manifest struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *csv.Writer
+}
+
+// createManifest creates the manifest file with its header line.
+func createManifest(filename string) (*manifest, error) {
+	file, err := options.FileMode.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	m := &manifest{file: file, writer: csv.NewWriter(file)}
+	if err := m.write("z", "x", "y", "url", "status", "bytes", "outcome", "error"); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// Add records the outcome of the tile. tile is nil for tiles
+// which failed or were skipped without requesting them.
+func (m *manifest) Add(tileID mercantile.TileID, tile *tiles.Tile, outcome string, err error) error {
+	var status, size, message string
+	url, statusCode := requested(tile, err)
+	if statusCode != 0 {
+		status = fmt.Sprint(statusCode)
+	}
+	if tile != nil {
+		size = fmt.Sprint(len(tile.Content))
+	}
+	if err != nil {
+		message = err.Error()
+	}
+	return m.write(fmt.Sprint(tileID.Z), fmt.Sprint(tileID.X), fmt.Sprint(tileID.Y), url, status, size, outcome, message)
+}
+
+func (m *manifest) write(record ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.writer.Write(record); err != nil {
+		return err
+	}
+	m.writer.Flush()
+	return m.writer.Error()
+}
+
+// Close closes the manifest file.
+func (m *manifest) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.writer.Flush()
+	if err := m.writer.Error(); err != nil {
+		m.file.Close()
+		return err
+	}
+	return m.file.Close()
+}
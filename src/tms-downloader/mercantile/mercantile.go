@@ -48,6 +48,13 @@ func Ul(tile TileID) LngLat {
 	return LngLat{lonDeg, latDeg}
 }
 
+// Bounds returns the geographic (lon, lat) bounding box of a tile
+func Bounds(tile TileID) Bbox {
+	ul := Ul(tile)
+	lr := Ul(TileID{tile.X + 1, tile.Y + 1, tile.Z})
+	return Bbox{ul.Lng, lr.Lat, lr.Lng, ul.Lat}
+}
+
 // XyBounds returns the Spherical Mercator bounding box of a tile
 func XyBounds(tile TileID) Bbox {
 	left, top := Xy(Ul(tile))
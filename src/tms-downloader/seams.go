@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"tms-downloader/mercantile"
+	"tms-downloader/tiles"
+)
+
+// Number of adjacent tile pairs per direction sampled by checkSeams.
+const seamSamples = 5
+
+// Mean edge pixel difference (0-255) above which a seam is reported.
+const seamThreshold = 16.0
+
+// checkSeams downloads a few pairs of adjacent tiles and compares
+// the pixels along their shared edge. Large differences usually
+// mean the y axis or zoom is addressed wrong. Returns the number
+// of suspicious seams found.
+func checkSeams(tileIDs []mercantile.TileID, options tiles.Options) (int, error) {
+	known := make(map[mercantile.TileID]bool, len(tileIDs))
+	for _, tileID := range tileIDs {
+		known[tileID] = true
+	}
+
+	var horizontal, vertical [][2]mercantile.TileID
+	for _, tileID := range tileIDs {
+		right := mercantile.TileID{X: tileID.X + 1, Y: tileID.Y, Z: tileID.Z}
+		if known[right] && len(horizontal) < seamSamples {
+			horizontal = append(horizontal, [2]mercantile.TileID{tileID, right})
+		}
+		below := mercantile.TileID{X: tileID.X, Y: tileID.Y + 1, Z: tileID.Z}
+		if known[below] && len(vertical) < seamSamples {
+			vertical = append(vertical, [2]mercantile.TileID{tileID, below})
+		}
+	}
+	if len(horizontal) == 0 && len(vertical) == 0 {
+		return 0, fmt.Errorf("No adjacent tiles to compare, enlarge the bbox")
+	}
+
+	images := make(map[mercantile.TileID]image.Image)
+	fetch := func(tileID mercantile.TileID) (image.Image, error) {
+		if img, ok := images[tileID]; ok {
+			return img, nil
+		}
+		tile, err := tiles.Get(context.Background(), tiles.GetTileID(tileID.X, tileID.Y, tileID.Z), options)
+		if err != nil {
+			return nil, err
+		}
+		img, _, err := image.Decode(bytes.NewReader(tile.Content))
+		if err != nil {
+			return nil, fmt.Errorf("tile %v/%v/%v: %v", tileID.Z, tileID.X, tileID.Y, err)
+		}
+		images[tileID] = img
+		return img, nil
+	}
+
+	seams := 0
+	compare := func(pairs [][2]mercantile.TileID, vertical bool) error {
+		for _, pair := range pairs {
+			a, err := fetch(pair[0])
+			if err != nil {
+				return err
+			}
+			b, err := fetch(pair[1])
+			if err != nil {
+				return err
+			}
+			diff := edgeDifference(a, b, vertical)
+			status := "ok"
+			if diff > seamThreshold {
+				status = "SEAM"
+				seams++
+			}
+			fmt.Printf("%v/%v/%v | %v/%v/%v: %.1f %v\n",
+				pair[0].Z, pair[0].X, pair[0].Y,
+				pair[1].Z, pair[1].X, pair[1].Y,
+				diff, status,
+			)
+		}
+		return nil
+	}
+	if err := compare(horizontal, false); err != nil {
+		return seams, err
+	}
+	if err := compare(vertical, true); err != nil {
+		return seams, err
+	}
+	return seams, nil
+}
+
+// edgeDifference returns the mean absolute gray level difference
+// between the touching edges of two images. If vertical is false,
+// b is right of a, otherwise b is below a.
+func edgeDifference(a, b image.Image, vertical bool) float64 {
+	ab, bb := a.Bounds(), b.Bounds()
+	n := ab.Dy()
+	if vertical {
+		n = ab.Dx()
+	}
+	var sum float64
+	var count int
+	for i := 0; i < n; i++ {
+		var pa, pb float64
+		if vertical {
+			if bb.Min.X+i >= bb.Max.X {
+				break
+			}
+			pa = gray(a, ab.Min.X+i, ab.Max.Y-1)
+			pb = gray(b, bb.Min.X+i, bb.Min.Y)
+		} else {
+			if bb.Min.Y+i >= bb.Max.Y {
+				break
+			}
+			pa = gray(a, ab.Max.X-1, ab.Min.Y+i)
+			pb = gray(b, bb.Min.X, bb.Min.Y+i)
+		}
+		if pa > pb {
+			sum += pa - pb
+		} else {
+			sum += pb - pa
+		}
+		count++
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
+
+// gray returns the luminance (0-255) of a pixel.
+func gray(img image.Image, x, y int) float64 {
+	r, g, b, _ := img.At(x, y).RGBA()
+	return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257.0
+}
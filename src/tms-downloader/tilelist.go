@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+
+	"tms-downloader/mercantile"
+)
+
+// writeTileList writes given tiles to the file, one tile
+// per line in z/x/y format, followed by the tile's URL if
+// urls has one.
+func writeTileList(filename string, tileIDs []mercantile.TileID, urls map[mercantile.TileID]string) error {
+	file, err := options.FileMode.Create(filename)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for _, tileID := range tileIDs {
+		if url := urls[tileID]; url != "" {
+			fmt.Fprintf(writer, "%v/%v/%v %v\n", tileID.Z, tileID.X, tileID.Y, url)
+		} else {
+			fmt.Fprintf(writer, "%v/%v/%v\n", tileID.Z, tileID.X, tileID.Y)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// limitPerZoom returns at most limit first tiles of each zoom.
+func limitPerZoom(tileIDs []mercantile.TileID, limit int) []mercantile.TileID {
+	counts := make(map[int]int)
+	var limited []mercantile.TileID
+	for _, tileID := range tileIDs {
+		if counts[tileID.Z] < limit {
+			limited = append(limited, tileID)
+			counts[tileID.Z]++
+		}
+	}
+	return limited
+}
@@ -0,0 +1,91 @@
+package tiles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+)
+
+// Adjust changes brightness and gamma of the tile's image.
+// Brightness is added to each color channel (-1 to 1, 0 keeps
+// the image), then gamma applied (0 or 1 keeps the image,
+// larger brightens mid tones). Alpha is kept, and the palette of
+// paletted images. Tiles which are not PNG, JPEG or GIF images,
+// like vector tiles, are left as they are, like animated GIFs.
+func Adjust(tile *Tile, brightness float64, gamma float64) error {
+	src, format, err := image.Decode(bytes.NewReader(tile.Content))
+	if errors.Is(err, image.ErrFormat) || (err == nil && format == "gif" && animated(tile.Content)) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if gamma == 0 {
+		gamma = 1
+	}
+	var table [256]uint8
+	for i := range table {
+		v := float64(i)/255 + brightness
+		v = math.Max(0, math.Min(1, v))
+		table[i] = uint8(math.Round(math.Pow(v, 1/gamma) * 255))
+	}
+
+	var img image.Image
+	if paletted, ok := src.(*image.Paletted); ok {
+		for i, c := range paletted.Palette {
+			nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+			nrgba.R, nrgba.G, nrgba.B = table[nrgba.R], table[nrgba.G], table[nrgba.B]
+			paletted.Palette[i] = nrgba
+		}
+		img = paletted
+	} else {
+		nrgba := image.NewNRGBA(src.Bounds())
+		draw.Draw(nrgba, nrgba.Bounds(), src, src.Bounds().Min, draw.Src)
+		for i := 0; i < len(nrgba.Pix); i += 4 {
+			nrgba.Pix[i] = table[nrgba.Pix[i]]
+			nrgba.Pix[i+1] = table[nrgba.Pix[i+1]]
+			nrgba.Pix[i+2] = table[nrgba.Pix[i+2]]
+		}
+		img = nrgba
+	}
+
+	content, err := encodeImage(img, format)
+	if err != nil {
+		return err
+	}
+	tile.Content = content
+	return nil
+}
+
+// encodeImage encodes the image in format, as
+// returned by image.Decode: "jpeg", "gif" or "png".
+func encodeImage(img image.Image, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	case "png":
+		err = png.Encode(&buf, img)
+	default:
+		err = fmt.Errorf("Images in %v can not be encoded", format)
+	}
+	return buf.Bytes(), err
+}
+
+// animated tells if the content is a GIF of several
+// frames, which encodeImage would reduce to the first.
+func animated(content []byte) bool {
+	all, err := gif.DecodeAll(bytes.NewReader(content))
+	return err == nil && len(all.Image) > 1
+}
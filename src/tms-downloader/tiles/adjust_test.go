@@ -0,0 +1,94 @@
+package tiles
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/Luqqk/wms-tiles-downloader/pkg/mercantile"
+)
+
+// gifTile returns a GIF of a tile in two colors, the upper
+// half in the first one, with frames copies of it.
+func gifTile(t *testing.T, frames int, palette color.Palette) []byte {
+	t.Helper()
+	img := image.NewPaletted(image.Rect(0, 0, 256, 256), palette)
+	for i := 128 * 256; i < len(img.Pix); i++ {
+		img.Pix[i] = 1
+	}
+	all := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		all.Image = append(all.Image, img)
+		all.Delay = append(all.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, all); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestAdjustGIF(t *testing.T) {
+	palette := color.Palette{color.NRGBA{R: 100, G: 100, B: 100, A: 255}, color.NRGBA{R: 10, G: 200, B: 10, A: 255}}
+	tile := &Tile{Content: gifTile(t, 1, palette)}
+	if err := Adjust(tile, 0.2, 1); err != nil {
+		t.Fatal(err)
+	}
+	img, format, err := image.Decode(bytes.NewReader(tile.Content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "gif" {
+		t.Fatalf("adjusted tile is %v, want gif", format)
+	}
+	paletted, ok := img.(*image.Paletted)
+	if !ok || len(paletted.Palette) != 2 {
+		t.Fatalf("adjusted tile is %T, want a paletted image of 2 colors", img)
+	}
+	want := []color.NRGBA{{R: 151, G: 151, B: 151, A: 255}, {R: 61, G: 251, B: 61, A: 255}}
+	for i, p := range []image.Point{{0, 0}, {0, 255}} {
+		if got := color.NRGBAModel.Convert(img.At(p.X, p.Y)); got != want[i] {
+			t.Errorf("pixel at %v is %v, want %v", p, got, want[i])
+		}
+	}
+}
+
+func TestAdjustAnimatedGIF(t *testing.T) {
+	content := gifTile(t, 2, color.Palette{color.White, color.Black})
+	tile := &Tile{Content: content}
+	if err := Adjust(tile, 0.2, 2); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tile.Content, content) {
+		t.Error("animated GIF was changed")
+	}
+	tile.TileID = mercantile.TileID{X: 1, Y: 0, Z: 1}
+	if err := Reproject(tile, 4326); err == nil {
+		t.Error("animated GIF was reprojected")
+	}
+}
+
+func TestReprojectKeepsFormat(t *testing.T) {
+	palette := color.Palette{color.White, color.NRGBA{R: 10, G: 200, B: 10, A: 255}}
+	tests := map[string][]byte{
+		"png": pngTile(t, color.White),
+		"gif": gifTile(t, 1, palette),
+	}
+	for want, content := range tests {
+		tile := &Tile{Content: content, TileID: mercantile.TileID{X: 1, Y: 0, Z: 1}}
+		if err := Reproject(tile, 4326); err != nil {
+			t.Errorf("%v: %v", want, err)
+			continue
+		}
+		img, format, err := image.Decode(bytes.NewReader(tile.Content))
+		if err != nil || format != want {
+			t.Errorf("%v: reprojected tile is %v, error %v", want, format, err)
+			continue
+		}
+		if _, ok := img.(*image.Paletted); want == "gif" && !ok {
+			t.Errorf("reprojected GIF is %T, want a paletted image", img)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package tiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+)
+
+// Area is the area of interest read from a GeoJSON file:
+// polygons, each an outer ring followed by its holes, of
+// WGS84 lon/lat positions.
+type Area [][][][2]float64
+
+// geoJSON holds the members of any GeoJSON object
+// needed to find its polygons.
+type geoJSON struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *geoJSON        `json:"geometry"`
+	Geometries  []*geoJSON      `json:"geometries"`
+	Features    []*geoJSON      `json:"features"`
+}
+
+// polygons returns the polygons of the object. Geometries
+// other than polygons are ignored.
+func (object *geoJSON) polygons() (Area, error) {
+	if object == nil {
+		return nil, nil
+	}
+	switch object.Type {
+	case "Polygon":
+		var polygon [][][2]float64
+		if err := json.Unmarshal(object.Coordinates, &polygon); err != nil {
+			return nil, err
+		}
+		return Area{polygon}, nil
+	case "MultiPolygon":
+		var area Area
+		err := json.Unmarshal(object.Coordinates, &area)
+		return area, err
+	case "Feature":
+		return object.Geometry.polygons()
+	}
+	var area Area
+	for _, member := range append(object.Geometries, object.Features...) {
+		polygons, err := member.polygons()
+		if err != nil {
+			return nil, err
+		}
+		area = append(area, polygons...)
+	}
+	return area, nil
+}
+
+// ReadArea reads the polygons of a GeoJSON Polygon, MultiPolygon,
+// Feature, FeatureCollection or GeometryCollection from the file.
+func ReadArea(filename string) (Area, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var object geoJSON
+	if err := json.Unmarshal(content, &object); err != nil {
+		return nil, fmt.Errorf("GeoJSON file %v: %v", filename, err)
+	}
+	area, err := object.polygons()
+	if err != nil {
+		return nil, fmt.Errorf("GeoJSON file %v: %v", filename, err)
+	}
+	for _, polygon := range area {
+		if len(polygon) > 0 && len(polygon[0]) > 0 {
+			return area, nil
+		}
+	}
+	return nil, fmt.Errorf("GeoJSON file %v has no polygon geometry", filename)
+}
+
+// Bbox returns the envelope of the outer rings of the area.
+func (area Area) Bbox() Bbox {
+	bbox := Bbox{Left: math.Inf(1), Bottom: math.Inf(1), Right: math.Inf(-1), Top: math.Inf(-1)}
+	for _, polygon := range area {
+		if len(polygon) == 0 {
+			continue
+		}
+		for _, position := range polygon[0] {
+			bbox.Left = math.Min(bbox.Left, position[0])
+			bbox.Bottom = math.Min(bbox.Bottom, position[1])
+			bbox.Right = math.Max(bbox.Right, position[0])
+			bbox.Top = math.Max(bbox.Top, position[1])
+		}
+	}
+	return bbox
+}
+
+// Intersects tells if the bbox overlaps the area: a corner of the
+// bbox is inside a polygon, a vertex of a polygon is inside the
+// bbox, or their edges cross.
+func (area Area) Intersects(bbox Bbox) bool {
+	corners := [][2]float64{
+		{bbox.Left, bbox.Bottom},
+		{bbox.Right, bbox.Bottom},
+		{bbox.Right, bbox.Top},
+		{bbox.Left, bbox.Top},
+	}
+	for _, polygon := range area {
+		if len(polygon) == 0 {
+			continue
+		}
+		for _, corner := range corners {
+			if polygonContains(polygon, corner) {
+				return true
+			}
+		}
+		for _, position := range polygon[0] {
+			if position[0] >= bbox.Left && position[0] <= bbox.Right && position[1] >= bbox.Bottom && position[1] <= bbox.Top {
+				return true
+			}
+		}
+		for _, ring := range polygon {
+			for i := 1; i < len(ring); i++ {
+				for j := range corners {
+					if segmentsCross(ring[i-1], ring[i], corners[j], corners[(j+1)%len(corners)]) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// polygonContains tells if the point is inside the outer ring
+// of the polygon and not inside any of its holes.
+func polygonContains(polygon [][][2]float64, point [2]float64) bool {
+	if !ringContains(polygon[0], point) {
+		return false
+	}
+	for _, hole := range polygon[1:] {
+		if ringContains(hole, point) {
+			return false
+		}
+	}
+	return true
+}
+
+// ringContains tells if the point is inside the ring,
+// by the even-odd rule.
+func ringContains(ring [][2]float64, point [2]float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a[1] > point[1]) != (b[1] > point[1]) &&
+			point[0] < (b[0]-a[0])*(point[1]-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// segmentsCross tells if the segments a1-a2 and b1-b2 intersect.
+func segmentsCross(a1, a2, b1, b2 [2]float64) bool {
+	d1 := orientation(b1, b2, a1)
+	d2 := orientation(b1, b2, a2)
+	d3 := orientation(a1, a2, b1)
+	d4 := orientation(a1, a2, b2)
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) &&
+		((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
+
+// orientation is positive if c is left of the line
+// from a to b, negative if right and zero if on it.
+func orientation(a, b, c [2]float64) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+// ReadBboxFile sets Bbox to the envelope of the area in
+// BboxFile, and Area to the area so that tiles outside of
+// it can be left out (see Area.Intersects). Must be called
+// after flag.Parse.
+func (options *Options) ReadBboxFile() error {
+	if options.Bbox != (Bbox{}) {
+		return errors.New("Use either bbox or bbox file, not both")
+	}
+	area, err := ReadArea(options.BboxFile)
+	if err != nil {
+		return err
+	}
+	options.Bbox = area.Bbox()
+	options.Area = area
+	return nil
+}
@@ -0,0 +1,78 @@
+package tiles
+
+import "testing"
+
+// square returns the closed ring of a square.
+func square(left, bottom, right, top float64) [][2]float64 {
+	return [][2]float64{{left, bottom}, {right, bottom}, {right, top}, {left, top}, {left, bottom}}
+}
+
+func TestAreaIntersects(t *testing.T) {
+	// A square with a hole, and a thin cross
+	// of which no vertex is inside the bbox.
+	holed := [][][2]float64{square(0, 0, 10, 10), square(2, 2, 8, 8)}
+	cross := [][][2]float64{{{-1, 4.5}, {11, 4.5}, {11, 5.5}, {-1, 5.5}, {-1, 4.5}}}
+	tests := []struct {
+		name string
+		area Area
+		bbox Bbox
+		want bool
+	}{
+		{"bbox inside polygon", Area{holed}, Bbox{Left: 0.5, Bottom: 0.5, Right: 1.5, Top: 1.5}, true},
+		{"polygon inside bbox", Area{holed}, Bbox{Left: -1, Bottom: -1, Right: 11, Top: 11}, true},
+		{"edges crossing", Area{cross}, Bbox{Left: 4, Bottom: 3, Right: 6, Top: 7}, true},
+		{"bbox inside hole", Area{holed}, Bbox{Left: 3, Bottom: 3, Right: 7, Top: 7}, false},
+		{"bbox across hole edge", Area{holed}, Bbox{Left: 7, Bottom: 3, Right: 9, Top: 4}, true},
+		{"bbox outside", Area{holed}, Bbox{Left: 20, Bottom: 20, Right: 21, Top: 21}, false},
+		{"second polygon of multipolygon", Area{holed, {square(20, 20, 30, 30)}}, Bbox{Left: 24, Bottom: 24, Right: 25, Top: 25}, true},
+		{"between polygons of multipolygon", Area{holed, {square(20, 20, 30, 30)}}, Bbox{Left: 14, Bottom: 14, Right: 15, Top: 15}, false},
+		{"empty polygon", Area{{}}, Bbox{Left: 0, Bottom: 0, Right: 1, Top: 1}, false},
+	}
+	for _, test := range tests {
+		if got := test.area.Intersects(test.bbox); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRingContains(t *testing.T) {
+	// A concave ring, the notch at the top
+	// between x 4 and 6 down to y 5.
+	ring := [][2]float64{{0, 0}, {10, 0}, {10, 10}, {6, 10}, {5, 5}, {4, 10}, {0, 10}, {0, 0}}
+	tests := []struct {
+		point [2]float64
+		want  bool
+	}{
+		{[2]float64{1, 1}, true},
+		{[2]float64{5, 2}, true},
+		{[2]float64{5, 8}, false},
+		{[2]float64{3, 8}, true},
+		{[2]float64{-1, 5}, false},
+		{[2]float64{11, 5}, false},
+		{[2]float64{5, 11}, false},
+	}
+	for _, test := range tests {
+		if got := ringContains(ring, test.point); got != test.want {
+			t.Errorf("ringContains(%v) = %v, want %v", test.point, got, test.want)
+		}
+	}
+}
+
+func TestSegmentsCross(t *testing.T) {
+	tests := []struct {
+		name           string
+		a1, a2, b1, b2 [2]float64
+		want           bool
+	}{
+		{"crossing", [2]float64{0, 0}, [2]float64{2, 2}, [2]float64{0, 2}, [2]float64{2, 0}, true},
+		{"parallel", [2]float64{0, 0}, [2]float64{2, 0}, [2]float64{0, 1}, [2]float64{2, 1}, false},
+		{"collinear", [2]float64{0, 0}, [2]float64{2, 0}, [2]float64{1, 0}, [2]float64{3, 0}, false},
+		{"apart", [2]float64{0, 0}, [2]float64{1, 1}, [2]float64{2, 0}, [2]float64{3, -1}, false},
+		{"touching at an end", [2]float64{0, 0}, [2]float64{1, 1}, [2]float64{1, 1}, [2]float64{2, 0}, false},
+	}
+	for _, test := range tests {
+		if got := segmentsCross(test.a1, test.a2, test.b1, test.b2); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
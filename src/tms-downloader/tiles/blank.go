@@ -0,0 +1,51 @@
+package tiles
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Hashes stores MD5 checksums of tile contents, in hex.
+type Hashes []string
+
+// String is the method to format the flag's value, part of the flag.Value interface.
+// The String method's output will be used in diagnostics.
+func (hashes *Hashes) String() string {
+	return fmt.Sprint(*hashes)
+}
+
+// Set is the method to set the flag value, part of the flag.Value interface.
+// Converts comma-separated values (string in "hash,hash,(...)" format)
+// to Hashes type.
+func (hashes *Hashes) Set(value string) error {
+	for _, hash := range strings.Split(value, ",") {
+		hash = strings.ToLower(strings.TrimSpace(hash))
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*md5.Size {
+			return fmt.Errorf("Invalid MD5 checksum %q", hash)
+		}
+		*hashes = append(*hashes, hash)
+	}
+	return nil
+}
+
+// Blank tells if the tile is a placeholder for an area
+// without data, which is not worth saving: empty if
+// options.SkipEmpty is set, or one of options.SkipHashes.
+func Blank(tile *Tile, options Options) bool {
+	if options.SkipEmpty && len(tile.Content) == 0 {
+		return true
+	}
+	if len(options.SkipHashes) == 0 {
+		return false
+	}
+	sum := md5.Sum(tile.Content)
+	hash := hex.EncodeToString(sum[:])
+	for _, skip := range options.SkipHashes {
+		if hash == skip {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package tiles
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gregjones/httpcache"
+	"github.com/gregjones/httpcache/diskcache"
+)
+
+// NewCacheTransport returns a RoundTripper which caches responses
+// in the directory dir, honoring Cache-Control, Expires and ETag
+// headers of the responses. The layout is the one of
+// github.com/gregjones/httpcache/diskcache (one file per request,
+// named by the md5 of the URL, holding the raw HTTP response), so
+// the cache can be shared with other tools using that package.
+// Query parameters listed in stripQuery are left out of the cache
+// key ("*" leaves out the whole query), but still sent to the server.
+// Requests are sent through next, or the default transport if nil.
+func NewCacheTransport(dir string, stripQuery QueryKeys, next http.RoundTripper) http.RoundTripper {
+	var cache httpcache.Cache = diskcache.New(dir)
+	if len(stripQuery) > 0 {
+		cache = strippingCache{cache: cache, keys: stripQuery}
+	}
+	transport := httpcache.NewTransport(cache)
+	transport.Transport = next
+	return transport
+}
+
+// QueryKeys stores names of URL query parameters.
+type QueryKeys []string
+
+// String is the method to format the flag's value, part of the flag.Value interface.
+// The String method's output will be used in diagnostics.
+func (keys *QueryKeys) String() string {
+	return fmt.Sprint(*keys)
+}
+
+// Set is the method to set the flag value, part of the flag.Value interface.
+// Converts comma-separated values (string in "key,key,(...)" format)
+// to QueryKeys type.
+func (keys *QueryKeys) Set(value string) error {
+	for _, key := range strings.Split(value, ",") {
+		if key == "" {
+			return fmt.Errorf("Empty query parameter name in %q", value)
+		}
+		*keys = append(*keys, key)
+	}
+	return nil
+}
+
+// Strip removes the parameters from the query of rawURL.
+func (keys QueryKeys) Strip(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	for _, key := range keys {
+		if key == "*" {
+			q = url.Values{}
+			break
+		}
+		q.Del(key)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// strippingCache is a httpcache.Cache which ignores
+// volatile query parameters in the URLs of cache keys.
+type strippingCache struct {
+	cache httpcache.Cache
+	keys  QueryKeys
+}
+
+func (c strippingCache) Get(key string) ([]byte, bool) {
+	return c.cache.Get(c.keys.Strip(key))
+}
+
+func (c strippingCache) Set(key string, responseBytes []byte) {
+	c.cache.Set(c.keys.Strip(key), responseBytes)
+}
+
+func (c strippingCache) Delete(key string) {
+	c.cache.Delete(c.keys.Strip(key))
+}
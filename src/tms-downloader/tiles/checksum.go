@@ -0,0 +1,32 @@
+package tiles
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"path"
+)
+
+// checksumHashes are the digests SaveChecksum can record,
+// by the extension of the checksum file.
+var checksumHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha256": sha256.New,
+}
+
+// SaveChecksum saves the digest of the tile's content next to the
+// tile, y.png.sha256 for y.png, in the format of md5sum and sha256sum
+// so that "sha256sum -c" verifies the saved tile. algorithm is "md5"
+// or "sha256".
+func SaveChecksum(tile *Tile, algorithm string, mode FileMode) error {
+	newHash, ok := checksumHashes[algorithm]
+	if !ok {
+		return fmt.Errorf("Unknown checksum %q, use md5 or sha256", algorithm)
+	}
+	digest := newHash()
+	digest.Write(tile.Content)
+	content := fmt.Sprintf("%v  %v\n", hex.EncodeToString(digest.Sum(nil)), tile.Name)
+	return mode.WriteFile(path.Join(tile.Path, tile.Name+"."+algorithm), []byte(content))
+}
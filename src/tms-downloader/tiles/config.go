@@ -0,0 +1,82 @@
+package tiles
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReadConfigFile reads a YAML (or JSON) file mapping
+// command-line flag names to values, e.g.
+//
+//	url: https://tile.example.com/{z}/{x}/{y}.png
+//	zooms: 3-10
+//	bbox: 19.0,59.5,32.0,70.1
+//	header:
+//	  - "X-Api-Key: abc"
+//
+// It returns the values of each name as strings, to be parsed
+// like on the command line: one for a scalar, one per item for
+// a list, setting repeatable flags once per item. The layers
+// section is left to ReadConfigLayers.
+func ReadConfigFile(filename string) (map[string][]string, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return nil, fmt.Errorf("Config file %v: %v", filename, err)
+	}
+	config := make(map[string][]string, len(values))
+	for name, value := range values {
+		if name == "layers" {
+			continue
+		}
+		items, isList := value.([]interface{})
+		if !isList {
+			items = []interface{}{value}
+		}
+		for _, item := range items {
+			config[name] = append(config[name], fmt.Sprint(item))
+		}
+	}
+	return config, nil
+}
+
+// LoadFromFile sets the options named in the config file (see
+// ReadConfigFile), parsing their values like the flags of
+// RegisterFlags, and Layers (see ReadConfigLayers). Options not
+// in the file are left as they are, values of repeatable options
+// are added to theirs.
+func (options *Options) LoadFromFile(filename string) error {
+	config, err := ReadConfigFile(filename)
+	if err != nil {
+		return err
+	}
+	layers, err := ReadConfigLayers(filename)
+	if err != nil {
+		return err
+	}
+	// Registering sets the defaults of all flags.
+	kept := *options
+	flags := flag.NewFlagSet(filename, flag.ContinueOnError)
+	options.RegisterFlags(flags)
+	*options = kept
+	for name, values := range config {
+		if flags.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("Config file %v: unknown option %q", filename, name)
+		}
+		for _, value := range values {
+			if err := flags.Set(name, value); err != nil {
+				return fmt.Errorf("Config file %v: invalid %v: %v", filename, name, err)
+			}
+		}
+	}
+	if len(layers) > 0 {
+		options.Layers = layers
+	}
+	return nil
+}
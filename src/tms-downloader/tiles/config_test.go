@@ -0,0 +1,111 @@
+package tiles
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestReadConfigFile(t *testing.T) {
+	filename := path.Join(t.TempDir(), "config.yaml")
+	content := `url: https://tile.example.com/{z}/{x}/{y}.png
+zooms: 3-10
+bbox: 19.0,59.5,32.0,70.1
+concurrency: 4
+wait-time: 1.5
+resume: true
+header:
+  - "X-Api-Key: abc"
+  - "Referer: https://example.com/"
+`
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := ReadConfigFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"url":         {"https://tile.example.com/{z}/{x}/{y}.png"},
+		"zooms":       {"3-10"},
+		"bbox":        {"19.0,59.5,32.0,70.1"},
+		"concurrency": {"4"},
+		"wait-time":   {"1.5"},
+		"resume":      {"true"},
+		"header":      {"X-Api-Key: abc", "Referer: https://example.com/"},
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("got %v, want %v", config, want)
+	}
+}
+
+func TestReadConfigFileInvalid(t *testing.T) {
+	dir := t.TempDir()
+	filename := path.Join(dir, "config.yaml")
+	if err := os.WriteFile(filename, []byte("url: [unclosed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{filename, path.Join(dir, "missing.yaml")} {
+		if _, err := ReadConfigFile(name); err == nil {
+			t.Errorf("ReadConfigFile(%v) succeeded, want an error", name)
+		}
+	}
+}
+
+func TestLoadFromFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := path.Join(dir, "config.yaml")
+	content := `url: https://tile.example.com/{z}/{x}/{y}.png
+zooms: 3-5
+bbox: 19.0,59.5,32.0,70.1
+concurrency: 4
+resume: true
+file-mode: "0600"
+header:
+  - "X-Api-Key: abc"
+layers:
+  - name: roads
+    url: https://roads.example.com/{z}/{x}/{y}.png
+`
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	options := Options{OutputDir: "tiles", Concurrency: 2, Retries: 3}
+	if err := options.LoadFromFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	switch {
+	case options.URL != "https://tile.example.com/{z}/{x}/{y}.png":
+		t.Errorf("got url %v", options.URL)
+	case !reflect.DeepEqual(options.Zooms, Zooms{3, 4, 5}):
+		t.Errorf("got zooms %v", options.Zooms)
+	case options.Bbox != Bbox{Left: 19, Bottom: 59.5, Right: 32, Top: 70.1}:
+		t.Errorf("got bbox %v", options.Bbox)
+	case options.Concurrency != 4 || !options.Resume || options.FileMode != 0600:
+		t.Errorf("got concurrency %v, resume %v and file mode %v", options.Concurrency, options.Resume, options.FileMode)
+	case options.Headers["X-Api-Key"] == nil:
+		t.Errorf("got headers %v", options.Headers)
+	case len(options.Layers) != 1 || options.Layers[0].Name != "roads":
+		t.Errorf("got layers %+v", options.Layers)
+	}
+	// Options not in the file are kept, not set to defaults.
+	if options.OutputDir != "tiles" || options.Retries != 3 || options.WaitTime != 0 {
+		t.Errorf("got output %v, retries %v and wait %v, want tiles, 3 and 0", options.OutputDir, options.Retries, options.WaitTime)
+	}
+
+	for content, problem := range map[string]string{
+		"zooms: 5-3\n":         "inverted zooms",
+		"bbox: 1,2\n":          "short bbox",
+		"no-such-option: 1\n":  "unknown option",
+		"config: other.yaml\n": "nested config",
+	} {
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		options := Options{}
+		if err := options.LoadFromFile(filename); err == nil {
+			t.Errorf("%v was loaded", problem)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package tiles
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync"
+)
+
+// ContentStore saves tiles by content hash, like a git object
+// store, so identical tiles (blank ocean etc.) are stored once.
+// Contents go to objects/<2 hex>/<62 hex> of the SHA-256 hash,
+// and index.txt maps tiles to hashes in "z/x/y hash" lines.
+type ContentStore struct {
+	dir   string
+	mode  FileMode
+	mu    sync.Mutex
+	index *os.File
+	// Tiles is the number of tiles added,
+	// Objects the number of new objects written.
+	Tiles   int
+	Objects int
+}
+
+// OpenContentStore opens the store in the directory, creating it if
+// needed. Appends to the index of an existing store. Objects and the
+// index are written with the mode.
+func OpenContentStore(dir string, mode FileMode) (*ContentStore, error) {
+	if err := mode.mkdirAll(path.Join(dir, "objects")); err != nil {
+		return nil, err
+	}
+	index, err := os.OpenFile(path.Join(dir, "index.txt"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, mode.file())
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return &ContentStore{dir: dir, mode: mode, index: index}, nil
+}
+
+// Write saves the tile's content unless an object with
+// the same hash exists, and records it in the index.
+func (store *ContentStore) Write(tile *Tile) error {
+	sum := sha256.Sum256(tile.Content)
+	hash := hex.EncodeToString(sum[:])
+	objectDir := path.Join(store.dir, "objects", hash[:2])
+	objectPath := path.Join(objectDir, hash[2:])
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, err := os.Stat(objectPath); os.IsNotExist(err) {
+		if err := store.mode.mkdirAll(objectDir); err != nil {
+			return err
+		}
+		if err := store.mode.WriteFile(objectPath, tile.Content); err != nil {
+			return err
+		}
+		store.Objects++
+	}
+	if _, err := fmt.Fprintf(store.index, "%v/%v/%v %v\n", tile.TileID.Z, tile.TileID.X, tile.TileID.Y, hash); err != nil {
+		return storageError(err)
+	}
+	store.Tiles++
+	return nil
+}
+
+// ShowSummary prints to w the number of stored tiles
+// and new objects, and their ratio.
+func (store *ContentStore) ShowSummary(w io.Writer) {
+	ratio := 0.0
+	if store.Objects > 0 {
+		ratio = float64(store.Tiles) / float64(store.Objects)
+	}
+	fmt.Fprintf(w, "Content store: %v tiles, %v new objects, dedup ratio %.2f\n", store.Tiles, store.Objects, ratio)
+}
+
+// Close closes the index.
+func (store *ContentStore) Close() error {
+	return store.index.Close()
+}
@@ -0,0 +1,39 @@
+package tiles
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/Luqqk/wms-tiles-downloader/pkg/mercantile"
+)
+
+// CheckCORS sends a CORS preflight (OPTIONS) request for the tile
+// as a browser on origin would, and returns the response status
+// and its Access-Control-* headers.
+func CheckCORS(tileID mercantile.TileID, origin string, options Options) (int, http.Header, error) {
+	req, err := newRequest(context.Background(), tileID, options)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Method = http.MethodOptions
+	req.Body = nil
+	req.ContentLength = 0
+	req.Header.Set("User-Agent", userAgent(options))
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+
+	resp, err := httpClient(options).Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	header := http.Header{}
+	for name, values := range resp.Header {
+		if strings.HasPrefix(name, "Access-Control-") {
+			header[name] = values
+		}
+	}
+	return resp.StatusCode, header, nil
+}
@@ -0,0 +1,303 @@
+package tiles
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Luqqk/wms-tiles-downloader/pkg/mercantile"
+)
+
+// TileIDs returns the tiles of options.Bbox at options.Zooms,
+// only those intersecting options.Area if it is set.
+func TileIDs(options Options) []mercantile.TileID {
+	bbox := options.Bbox
+	tileIDs := mercantile.Tiles(bbox.Left, bbox.Bottom, bbox.Right, bbox.Top, options.Zooms)
+	if options.Area == nil {
+		return tileIDs
+	}
+	return ClipToArea(tileIDs, options.Area)
+}
+
+// ClipToArea returns the tiles intersecting the area.
+func ClipToArea(tileIDs []mercantile.TileID, area Area) []mercantile.TileID {
+	var clipped []mercantile.TileID
+	for _, tileID := range tileIDs {
+		if area.Intersects(Bbox(geographicBounds(tileID))) {
+			clipped = append(clipped, tileID)
+		}
+	}
+	return clipped
+}
+
+// Prepare adjusts and reprojects the tile before
+// writing it, if options ask so.
+func Prepare(tile *Tile, options Options) error {
+	if options.Brightness != 0 || (options.Gamma != 0 && options.Gamma != 1) {
+		if err := Adjust(tile, options.Brightness, options.Gamma); err != nil {
+			return err
+		}
+	}
+	if options.Reproject != 0 && options.Reproject != 3857 {
+		return Reproject(tile, options.Reproject)
+	}
+	return nil
+}
+
+// Hooks extend DownloadTiles for callers with outputs and
+// reports of their own, like the command. Nil fields are
+// left out.
+type Hooks struct {
+	// Jobs counts the tiles instead of new stats, so that
+	// several downloads, e.g. retries, share the counts.
+	Jobs *JobStats
+	// Writer writes the tiles instead of the writer picked
+	// by options. It is left open for the caller to close.
+	Writer TileWriter
+	// Done is called with the outcome of each counted tile,
+	// from the workers. An error aborts the download, which
+	// returns the error.
+	Done func(result Result) error
+}
+
+// Result is the outcome of a tile of DownloadTiles.
+type Result struct {
+	TileID mercantile.TileID
+	// Tile is the downloaded tile, nil if it was saved
+	// already or the request failed.
+	Tile *Tile
+	// Skipped tells that the tile was not written, being
+	// saved already (see ErrExists) or blank.
+	Skipped bool
+	// Err is why the tile failed.
+	Err error
+}
+
+// Download downloads the tiles of options (see TileIDs) with
+// options.Concurrency workers and writes them into the z/x/y
+// tree of options.OutputDir, or into options.MBTiles,
+// options.Zip or options.ContentAddressed if set. Tiles saved
+// already are skipped without downloading them (see saved), like
+// blank ones. Options are expected to be valid (see
+// ValidateOptions).
+//
+// Failed tiles are counted, but the run stops only when ctx is
+// cancelled, returning its error, or when tiles can not be
+// written (ErrStorage).
+func Download(ctx context.Context, options Options) (*JobStats, error) {
+	return DownloadTiles(ctx, TileIDs(options), options, Hooks{})
+}
+
+// DownloadTiles downloads the tiles like Download, extended
+// by hooks.
+func DownloadTiles(ctx context.Context, tileIDs []mercantile.TileID, options Options, hooks Hooks) (*JobStats, error) {
+	jobs := hooks.Jobs
+	if jobs == nil {
+		jobs = &JobStats{Start: time.Now(), All: len(tileIDs)}
+	}
+	if options.Session == nil {
+		options.Session = NewSession()
+	}
+
+	writer := hooks.Writer
+	// container is closed at the end, its
+	// error failing the download.
+	var container io.Closer
+	if writer == nil {
+		var err error
+		writer, container, err = openWriter(options)
+		if err != nil {
+			return jobs, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var abortErr error
+	var once sync.Once
+	abort := func(err error) {
+		once.Do(func() {
+			abortErr = err
+			cancel()
+		})
+	}
+
+	concurrency := options.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	// download downloads the tiles with the workers.
+	download := func(tileIDs []mercantile.TileID, workers int) {
+		queue := make(chan mercantile.TileID)
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for tileID := range queue {
+					result, counted := downloadTile(ctx, tileID, options, writer, jobs)
+					if errors.Is(result.Err, ErrStorage) {
+						abort(result.Err)
+					}
+					if counted && hooks.Done != nil {
+						if err := hooks.Done(result); err != nil {
+							abort(err)
+						}
+					}
+					// Tiles skipped without a request don't wait.
+					if !result.Skipped || result.Tile != nil {
+						sleep(ctx, options.Wait(tileID.Z))
+					}
+				}
+			}()
+		}
+	feed:
+		for _, tileID := range tileIDs {
+			select {
+			case queue <- tileID:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+		close(queue)
+		wg.Wait()
+	}
+	if options.ConcurrencyPerZoomAuto {
+		for _, zoomTileIDs := range byZoom(tileIDs) {
+			if ctx.Err() != nil {
+				break
+			}
+			download(zoomTileIDs, zoomWorkers(len(zoomTileIDs), concurrency))
+		}
+	} else {
+		download(tileIDs, concurrency)
+	}
+
+	err := ctx.Err()
+	if abortErr != nil {
+		err = abortErr
+	}
+	if container != nil {
+		if closeErr := container.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return jobs, err
+}
+
+// tilesPerWorker is the number of tiles of a
+// zoom level per worker, see zoomWorkers.
+const tilesPerWorker = 16
+
+// zoomWorkers returns the number of workers downloading
+// the tiles of a zoom level: one per tilesPerWorker tiles,
+// at least one and at most concurrency.
+func zoomWorkers(count int, concurrency int) int {
+	workers := count / tilesPerWorker
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > concurrency {
+		workers = concurrency
+	}
+	return workers
+}
+
+// byZoom groups the tiles by zoom level, lowest
+// first, keeping the order of the tiles of each.
+func byZoom(tileIDs []mercantile.TileID) [][]mercantile.TileID {
+	zooms := make(map[int][]mercantile.TileID)
+	for _, tileID := range tileIDs {
+		zooms[tileID.Z] = append(zooms[tileID.Z], tileID)
+	}
+	levels := make([]int, 0, len(zooms))
+	for z := range zooms {
+		levels = append(levels, z)
+	}
+	sort.Ints(levels)
+	groups := make([][]mercantile.TileID, len(levels))
+	for i, z := range levels {
+		groups[i] = zooms[z]
+	}
+	return groups
+}
+
+// openWriter returns the writer picked by options for
+// DownloadTiles, and the container to close if any.
+func openWriter(options Options) (TileWriter, io.Closer, error) {
+	switch {
+	case options.MBTiles != "":
+		mbtiles, err := OpenMBTiles(options.MBTiles, options)
+		return mbtiles, mbtiles, err
+	case options.Zip != "":
+		archive, err := CreateZipArchive(options.Zip, options)
+		return archive, archive, err
+	case options.ContentAddressed != "":
+		store, err := OpenContentStore(options.ContentAddressed, options.FileMode)
+		return store, store, err
+	default:
+		return FileWriter{Options: options}, nil, CheckOutputDir(options.OutputDir, options.FileMode)
+	}
+}
+
+// saved tells if downloadTile skips the tile without downloading
+// it, being saved already: with options.MaxAge if it is fresh,
+// otherwise in the z/x/y tree unless options.Overwrite is set.
+// With options.Resume a writer which is a TileStore is asked
+// instead of the tree.
+func saved(tileID mercantile.TileID, options Options, writer TileWriter) bool {
+	store, isStore := writer.(TileStore)
+	switch {
+	case options.MaxAge > 0:
+		return Fresh(tileID, options)
+	case options.Resume && isStore:
+		return store.Has(tileID)
+	case options.Resume:
+		return Downloaded(tileID, options)
+	case options.Overwrite || countSet(options.DataURIJSON, options.ContentAddressed, options.MBTiles, options.Zip) > 0:
+		return false
+	default:
+		return Downloaded(tileID, options)
+	}
+}
+
+// downloadTile downloads and writes the tile for DownloadTiles,
+// counting the result in jobs. Tiles left undone because
+// ctx was cancelled are not counted.
+func downloadTile(ctx context.Context, tileID mercantile.TileID, options Options, writer TileWriter, jobs *JobStats) (Result, bool) {
+	result := Result{TileID: tileID}
+	if saved(tileID, options, writer) {
+		result.Skipped = true
+		jobs.AddSkipped(tileID.Z)
+		return result, true
+	}
+	tile, err := Get(ctx, tileID, options)
+	result.Tile = tile
+	if err == nil && Blank(tile, options) {
+		result.Skipped = true
+		jobs.AddSkipped(tileID.Z)
+		return result, true
+	}
+	if err == nil {
+		err = Prepare(tile, options)
+	}
+	if err == nil {
+		err = writer.Write(tile)
+	}
+	switch {
+	case err == nil:
+		jobs.AddSucceeded(tileID.Z)
+	case err == ErrExists:
+		result.Skipped = true
+		jobs.AddSkipped(tileID.Z)
+	case ctx.Err() != nil && !errors.Is(err, ErrStorage):
+		return result, false
+	default:
+		result.Err = err
+		jobs.AddFailed(tileID.Z, 1)
+	}
+	return result, true
+}
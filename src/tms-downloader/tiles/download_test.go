@@ -0,0 +1,296 @@
+package tiles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pngTile returns a PNG image of a tile filled with c.
+func pngTile(t *testing.T, c color.Color) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, 256, 256))
+	for i := 0; i < 256; i++ {
+		for j := 0; j < 256; j++ {
+			img.Set(i, j, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// serveTile starts a server responding with content to all requests.
+func serveTile(t *testing.T, content []byte) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(content)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDownloadZeroOptionsKeepPixels(t *testing.T) {
+	red := color.NRGBA{R: 200, G: 10, B: 10, A: 255}
+	server := serveTile(t, pngTile(t, red))
+	options := Options{
+		URL:       server.URL + "/{z}/{x}/{y}.png",
+		Zooms:     Zooms{1},
+		Bbox:      Bbox{Left: 1, Bottom: 1, Right: 2, Top: 2},
+		OutputDir: t.TempDir(),
+	}
+
+	jobs, err := Download(context.Background(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs.All != 1 || jobs.Succeeded != 1 {
+		t.Fatalf("got %v of %v tiles succeeded, want 1 of 1", jobs.Succeeded, jobs.All)
+	}
+	file, err := os.Open(path.Join(options.OutputDir, "1", "1", "0.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	img, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := color.NRGBAModel.Convert(img.At(0, 0)); got != red {
+		t.Errorf("saved pixel is %v, want %v", got, red)
+	}
+}
+
+func TestDownloadTilesHooks(t *testing.T) {
+	server := serveTile(t, pngTile(t, color.White))
+	options := Options{
+		URL:         server.URL + "/{z}/{x}/{y}.png",
+		Zooms:       Zooms{1},
+		Bbox:        Bbox{Left: -180, Bottom: -85, Right: 180, Top: 85},
+		OutputDir:   t.TempDir(),
+		Concurrency: 2,
+	}
+	tileIDs := TileIDs(options)
+	jobs := &JobStats{All: 2 * len(tileIDs)}
+	var mu sync.Mutex
+	var results []Result
+	hooks := Hooks{
+		Jobs: jobs,
+		Done: func(result Result) error {
+			mu.Lock()
+			results = append(results, result)
+			mu.Unlock()
+			return nil
+		},
+	}
+
+	for run := 0; run < 2; run++ {
+		if _, err := DownloadTiles(context.Background(), tileIDs, options, hooks); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if jobs.Succeeded != 4 || jobs.Skipped != 4 {
+		t.Errorf("got %v succeeded and %v skipped tiles, want 4 and 4", jobs.Succeeded, jobs.Skipped)
+	}
+	if len(results) != 8 {
+		t.Fatalf("Done was called %v times, want 8", len(results))
+	}
+	for _, result := range results {
+		if result.Err != nil || (result.Tile == nil) != result.Skipped {
+			t.Errorf("tile %v: got tile %v and error %v", result.TileID, result.Tile, result.Err)
+		}
+	}
+}
+
+func TestDownloadTilesAbortsOnHookError(t *testing.T) {
+	server := serveTile(t, pngTile(t, color.White))
+	options := Options{
+		URL:       server.URL + "/{z}/{x}/{y}.png",
+		Zooms:     Zooms{3},
+		Bbox:      Bbox{Left: -180, Bottom: -85, Right: 180, Top: 85},
+		OutputDir: t.TempDir(),
+	}
+	stop := errors.New("stop")
+	jobs, err := DownloadTiles(context.Background(), TileIDs(options), options, Hooks{
+		Done: func(result Result) error { return stop },
+	})
+	if err != stop {
+		t.Fatalf("got error %v, want %v", err, stop)
+	}
+	if jobs.Succeeded != 1 {
+		t.Errorf("got %v succeeded tiles after aborting, want 1", jobs.Succeeded)
+	}
+}
+
+func TestDownloadSkipsSavedTiles(t *testing.T) {
+	var requests int32
+	content := pngTile(t, color.White)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(content)
+	}))
+	defer server.Close()
+	options := Options{
+		URL:       server.URL + "/{z}/{x}/{y}.png",
+		Zooms:     Zooms{1},
+		Bbox:      Bbox{Left: -180, Bottom: -85, Right: 180, Top: 85},
+		OutputDir: t.TempDir(),
+	}
+
+	tests := []struct {
+		overwrite          bool
+		requests           int32
+		succeeded, skipped int
+	}{
+		{false, 4, 4, 0},
+		// The tiles are there, none is requested.
+		{false, 0, 0, 4},
+		{true, 4, 4, 0},
+	}
+	for i, test := range tests {
+		atomic.StoreInt32(&requests, 0)
+		options.Overwrite = test.overwrite
+		jobs, err := Download(context.Background(), options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if requests != test.requests {
+			t.Errorf("run %v: got %v requests, want %v", i+1, requests, test.requests)
+		}
+		if jobs.Succeeded != test.succeeded || jobs.Skipped != test.skipped {
+			t.Errorf("run %v: got %v succeeded and %v skipped tiles, want %v and %v", i+1, jobs.Succeeded, jobs.Skipped, test.succeeded, test.skipped)
+		}
+	}
+}
+
+func TestDownloadConcurrencyPerZoomAuto(t *testing.T) {
+	content := pngTile(t, color.White)
+	var mu sync.Mutex
+	inFlight := 0
+	// Most requests in flight at the same time, by zoom.
+	most := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zoom := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")[0]
+		mu.Lock()
+		inFlight++
+		if inFlight > most[zoom] {
+			most[zoom] = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(2 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(content)
+	}))
+	defer server.Close()
+	options := Options{
+		URL:                    server.URL + "/{z}/{x}/{y}.png",
+		Zooms:                  Zooms{0, 2, 4},
+		Bbox:                   Bbox{Left: -180, Bottom: -85, Right: 180, Top: 85},
+		OutputDir:              t.TempDir(),
+		Concurrency:            8,
+		ConcurrencyPerZoomAuto: true,
+	}
+	jobs, err := Download(context.Background(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs.Succeeded != 1+16+256 {
+		t.Errorf("got %v succeeded tiles, want %v", jobs.Succeeded, 1+16+256)
+	}
+	// Zooms are downloaded in turn, 256 tiles
+	// of zoom 4 by all 8 workers at most.
+	for zoom, want := range map[string]int{"0": 1, "2": 1, "4": 8} {
+		if most[zoom] > want {
+			t.Errorf("zoom %v: got %v requests at the same time, want at most %v", zoom, most[zoom], want)
+		}
+	}
+	if most["4"] < 2 {
+		t.Errorf("zoom 4: got %v requests at the same time, want several", most["4"])
+	}
+}
+
+func TestZoomWorkers(t *testing.T) {
+	tests := []struct {
+		count, concurrency, want int
+	}{
+		{1, 8, 1},
+		{16, 8, 1},
+		{47, 8, 2},
+		{64, 8, 4},
+		{4096, 8, 8},
+		{4096, 1, 1},
+	}
+	for _, test := range tests {
+		if got := zoomWorkers(test.count, test.concurrency); got != test.want {
+			t.Errorf("zoomWorkers(%v, %v) = %v, want %v", test.count, test.concurrency, got, test.want)
+		}
+	}
+}
+
+func TestDownloadsHaveSessionsOfTheirOwn(t *testing.T) {
+	content := pngTile(t, color.White)
+	var mu sync.Mutex
+	var requests int
+	// Token and cookie sent with each request.
+	var tokens, cookies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		n := requests
+		tokens = append(tokens, r.Header.Get("X-Token"))
+		cookie, _ := r.Cookie("id")
+		if cookie != nil {
+			cookies = append(cookies, cookie.Value)
+		} else {
+			cookies = append(cookies, "")
+		}
+		mu.Unlock()
+		w.Header().Set("X-Token", fmt.Sprintf("token-%v", n))
+		http.SetCookie(w, &http.Cookie{Name: "id", Value: fmt.Sprintf("cookie-%v", n)})
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(content)
+	}))
+	defer server.Close()
+	options := Options{
+		URL:            server.URL + "/{z}/{x}/{y}.png",
+		Zooms:          Zooms{1},
+		Bbox:           Bbox{Left: -180, Bottom: -85, Right: 180, Top: 85},
+		OutputDir:      t.TempDir(),
+		Overwrite:      true,
+		SessionHeaders: HeaderNames{"X-Token"},
+		Cookies:        true,
+	}
+	for run := 0; run < 2; run++ {
+		if _, err := Download(context.Background(), options); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wantTokens := []string{"", "token-1", "token-1", "token-1", "", "token-5", "token-5", "token-5"}
+	if !reflect.DeepEqual(tokens, wantTokens) {
+		t.Errorf("got tokens %q, want %q", tokens, wantTokens)
+	}
+	if cookies[0] != "" || cookies[4] != "" || cookies[5] != "cookie-5" {
+		t.Errorf("got cookies %q, want none at the start of each download", cookies)
+	}
+}
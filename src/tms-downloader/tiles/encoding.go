@@ -0,0 +1,52 @@
+package tiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// acceptEncoding is sent with requests unless the
+// headers of options set an Accept-Encoding.
+const acceptEncoding = "gzip, deflate"
+
+// decodeContent decompresses the body of a response with the
+// Content-Encoding encoding. Unknown encodings are left as is.
+// A body which fails to decompress is likely truncated, so
+// the error wraps ErrCorrupt to retry it.
+func decodeContent(body []byte, encoding string) ([]byte, error) {
+	var decoded []byte
+	var err error
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		var reader *gzip.Reader
+		if reader, err = gzip.NewReader(bytes.NewReader(body)); err == nil {
+			decoded, err = ioutil.ReadAll(reader)
+		}
+	case "deflate":
+		var reader io.ReadCloser
+		if reader, err = zlib.NewReader(bytes.NewReader(body)); err == nil {
+			decoded, err = ioutil.ReadAll(reader)
+		}
+	default:
+		return body, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v encoding: %v", ErrCorrupt, encoding, err)
+	}
+	return decoded, nil
+}
+
+// decodeResponse decompresses body of resp unless
+// options.NoDecompress is set.
+func decodeResponse(resp *http.Response, body []byte, options Options) ([]byte, error) {
+	if options.NoDecompress {
+		return body, nil
+	}
+	return decodeContent(body, resp.Header.Get("Content-Encoding"))
+}
@@ -0,0 +1,81 @@
+package tiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// compress returns content compressed with the Content-Encoding.
+func compress(t *testing.T, content []byte, encoding string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	if encoding == "gzip" {
+		w = gzip.NewWriter(&buf)
+	} else {
+		w = zlib.NewWriter(&buf)
+	}
+	if _, err := w.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGetEncodedTile(t *testing.T) {
+	content := []byte("\x1a\x02vector tile")
+	for _, encoding := range []string{"gzip", "deflate"} {
+		encoded := compress(t, content, encoding)
+		var acceptEncoding string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			acceptEncoding = r.Header.Get("Accept-Encoding")
+			w.Header().Set("Content-Type", "application/vnd.mapbox-vector-tile")
+			w.Header().Set("Content-Encoding", encoding)
+			w.Write(encoded)
+		}))
+
+		tests := []struct {
+			noDecompress bool
+			want         []byte
+		}{
+			{false, content},
+			{true, encoded},
+		}
+		for _, test := range tests {
+			options := Options{URL: server.URL + "/{z}/{x}/{y}.pbf", OutputDir: t.TempDir(), NoDecompress: test.noDecompress}
+			tile, err := Get(context.Background(), testTile, options)
+			if err != nil {
+				t.Errorf("%v, NoDecompress %v: %v", encoding, test.noDecompress, err)
+				continue
+			}
+			if !bytes.Equal(tile.Content, test.want) {
+				t.Errorf("%v, NoDecompress %v: got %q, want %q", encoding, test.noDecompress, tile.Content, test.want)
+			}
+			if acceptEncoding != "gzip, deflate" {
+				t.Errorf("%v: got Accept-Encoding %q, want %q", encoding, acceptEncoding, "gzip, deflate")
+			}
+		}
+		server.Close()
+	}
+}
+
+func TestGetCorruptEncodedTile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write([]byte("not gzip"))
+	}))
+	defer server.Close()
+
+	options := Options{URL: server.URL + "/{z}/{x}/{y}.pbf", OutputDir: t.TempDir()}
+	if tile, err := Get(context.Background(), testTile, options); tile != nil || err == nil {
+		t.Errorf("got tile %v and error %v, want an error", tile, err)
+	}
+}
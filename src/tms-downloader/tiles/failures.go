@@ -0,0 +1,153 @@
+package tiles
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/Luqqk/wms-tiles-downloader/pkg/mercantile"
+)
+
+// Number of example tiles shown per failure category.
+const failureExamples = 3
+
+// FailureReport groups failed tiles by error category.
+// It is safe for concurrent use.
+type FailureReport struct {
+	mu       sync.Mutex
+	failures map[mercantile.TileID]failure
+}
+
+// failure is the error of a failed tile
+// and the URL which was requested.
+type failure struct {
+	err error
+	url string
+}
+
+// ErrorCategory returns a coarse category of
+// an error returned by Get or Save.
+func ErrorCategory(err error) string {
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var statusErr *StatusError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return "not found (404)"
+	case errors.As(err, &statusErr):
+		return fmt.Sprintf("status %d", statusErr.StatusCode)
+	case errors.Is(err, ErrCorrupt):
+		return "corrupt"
+	case errors.Is(err, ErrStorage):
+		return "storage"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &opErr):
+		return "connection"
+	case errors.Is(err, image.ErrFormat):
+		return "decode"
+	case errors.Is(err, os.ErrNotExist), errors.Is(err, os.ErrPermission):
+		return "file"
+	default:
+		return "other"
+	}
+}
+
+// Add records the failure of the tile requested from url,
+// replacing an earlier failure of it.
+func (report *FailureReport) Add(tileID mercantile.TileID, url string, err error) {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	if report.failures == nil {
+		report.failures = make(map[mercantile.TileID]failure)
+	}
+	report.failures[tileID] = failure{err: err, url: url}
+}
+
+// URL returns the URL requested for the failed tile,
+// empty if the tile has not failed.
+func (report *FailureReport) URL(tileID mercantile.TileID) string {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	return report.failures[tileID].url
+}
+
+// Remove forgets the failure of the tile,
+// for example when a retry succeeded.
+func (report *FailureReport) Remove(tileID mercantile.TileID) {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	delete(report.failures, tileID)
+}
+
+// Tiles returns the failed tiles ordered by z, x and y.
+func (report *FailureReport) Tiles() []mercantile.TileID {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	return report.sortedTiles()
+}
+
+// sortedTiles returns the failed tiles ordered by z, x
+// and y. The caller must hold report.mu.
+func (report *FailureReport) sortedTiles() []mercantile.TileID {
+	tileIDs := make([]mercantile.TileID, 0, len(report.failures))
+	for tileID := range report.failures {
+		tileIDs = append(tileIDs, tileID)
+	}
+	sort.Slice(tileIDs, func(i, j int) bool {
+		a, b := tileIDs[i], tileIDs[j]
+		if a.Z != b.Z {
+			return a.Z < b.Z
+		}
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		return a.Y < b.Y
+	})
+	return tileIDs
+}
+
+// Show prints to w the number of failures per category, most
+// frequent first, with a few example tiles of each.
+func (report *FailureReport) Show(w io.Writer) {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	if len(report.failures) == 0 {
+		return
+	}
+	tileIDs := report.sortedTiles()
+
+	counts := make(map[string]int)
+	examples := make(map[string][]string)
+	var categories []string
+	for _, tileID := range tileIDs {
+		err := report.failures[tileID].err
+		category := ErrorCategory(err)
+		if counts[category] == 0 {
+			categories = append(categories, category)
+		}
+		counts[category]++
+		if len(examples[category]) < failureExamples {
+			examples[category] = append(examples[category], fmt.Sprintf("%v/%v/%v (%v)", tileID.Z, tileID.X, tileID.Y, err))
+		}
+	}
+	sort.SliceStable(categories, func(i, j int) bool {
+		return counts[categories[i]] > counts[categories[j]]
+	})
+
+	fmt.Fprintln(w, "Failures:")
+	for _, category := range categories {
+		fmt.Fprintf(w, "  %v: %v\n", category, counts[category])
+		for _, example := range examples[category] {
+			fmt.Fprintf(w, "    %v\n", example)
+		}
+	}
+}
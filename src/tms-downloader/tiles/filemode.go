@@ -0,0 +1,101 @@
+package tiles
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+// DefaultFileMode is the permission of saved files if
+// no FileMode is given.
+const DefaultFileMode FileMode = 0644
+
+// FileMode stores the permission of saved files. Directories
+// get the matching search bits, 0755 for 0644. Zero means
+// DefaultFileMode.
+type FileMode os.FileMode
+
+// String is the method to format the flag's value, part of the flag.Value interface.
+// The String method's output will be used in diagnostics.
+func (mode *FileMode) String() string {
+	return fmt.Sprintf("%#o", uint32(*mode))
+}
+
+// Set is the method to set the flag value, part of the flag.Value interface.
+// Converts an octal permission (string in "0644" format) to FileMode.
+func (mode *FileMode) Set(value string) error {
+	perm, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || perm > 0777 {
+		return fmt.Errorf("File mode must be octal permission bits, e.g. 0644, got %q", value)
+	}
+	*mode = FileMode(perm)
+	return nil
+}
+
+// file returns the permission of files.
+func (mode FileMode) file() os.FileMode {
+	if mode == 0 {
+		mode = DefaultFileMode
+	}
+	return os.FileMode(mode)
+}
+
+// dir returns the permission of directories, with search
+// bits for those who can read the files.
+func (mode FileMode) dir() os.FileMode {
+	perm := mode.file()
+	return perm | (perm&0444)>>2
+}
+
+// mkdirAll creates the directory and its parents with
+// the directory permission of the mode.
+func (mode FileMode) mkdirAll(dir string) error {
+	return storageError(os.MkdirAll(dir, mode.dir()))
+}
+
+// WriteFile writes the file with the mode. A mode given
+// explicitly is also set on existing files, regardless
+// of umask.
+func (mode FileMode) WriteFile(filename string, content []byte) error {
+	if err := ioutil.WriteFile(filename, content, mode.file()); err != nil {
+		return storageError(err)
+	}
+	if mode != 0 {
+		return storageError(os.Chmod(filename, mode.file()))
+	}
+	return nil
+}
+
+// createDB creates the file of an SQLite database with the mode
+// if there is none, for SQLite to keep its permission, set also
+// on existing files if given explicitly. Content is kept.
+func (mode FileMode) createDB(filename string) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE, mode.file())
+	if err != nil {
+		return storageError(err)
+	}
+	if mode != 0 {
+		err = file.Chmod(mode.file())
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return storageError(err)
+}
+
+// Create creates or truncates the file for writing with the
+// mode, set also on existing files if given explicitly.
+func (mode FileMode) Create(filename string) (*os.File, error) {
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode.file())
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if mode != 0 {
+		if err := file.Chmod(mode.file()); err != nil {
+			file.Close()
+			return nil, storageError(err)
+		}
+	}
+	return file, nil
+}
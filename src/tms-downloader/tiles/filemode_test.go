@@ -0,0 +1,97 @@
+package tiles
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/Luqqk/wms-tiles-downloader/pkg/mercantile"
+)
+
+// checkMode fails the test if the file does not have the permission.
+func checkMode(t *testing.T, filename string, want os.FileMode) {
+	t.Helper()
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got := info.Mode().Perm(); got != want {
+		t.Errorf("%v has mode %#o, want %#o", filename, got, want)
+	}
+}
+
+func TestContentStoreFileMode(t *testing.T) {
+	dir := path.Join(t.TempDir(), "store")
+	store, err := OpenContentStore(dir, 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Write(&Tile{TileID: mercantile.TileID{X: 1, Y: 2, Z: 3}, Content: []byte("tile")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// The SHA-256 hash of "tile".
+	object := path.Join(dir, "objects", "8b", "668b8994aa845107399994593d0ca831520be5257f005351a0ec13e97a39be")
+	checkMode(t, object, 0640)
+	checkMode(t, path.Dir(object), 0750)
+	checkMode(t, path.Join(dir, "index.txt"), 0640)
+}
+
+func TestWriteProgressFileMode(t *testing.T) {
+	filename := path.Join(t.TempDir(), "progress.json")
+	jobs := &JobStats{All: 1}
+	if err := jobs.WriteProgress(filename, 0600); err != nil {
+		t.Fatal(err)
+	}
+	checkMode(t, filename, 0600)
+}
+
+func TestCheckOutputDirFileMode(t *testing.T) {
+	dir := path.Join(t.TempDir(), "tiles")
+	if err := CheckOutputDir(dir, 0600); err != nil {
+		t.Fatal(err)
+	}
+	checkMode(t, dir, 0700)
+}
+
+func TestDatabaseFileMode(t *testing.T) {
+	dir := t.TempDir()
+	filename := path.Join(dir, "tiles.mbtiles")
+	mbtiles, err := OpenMBTiles(filename, Options{FileMode: 0600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mbtiles.Write(&Tile{TileID: mercantile.TileID{X: 0, Y: 0, Z: 0}, Name: "0.png", Content: []byte("tile")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mbtiles.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkMode(t, filename, 0600)
+
+	// Existing files get a mode given explicitly.
+	if err := os.Chmod(filename, 0644); err != nil {
+		t.Fatal(err)
+	}
+	mbtiles, err = OpenMBTiles(filename, Options{FileMode: 0640, MergeMBTiles: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mbtiles.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkMode(t, filename, 0640)
+
+	indexName := path.Join(dir, "index.db")
+	index, err := OpenIndex(indexName, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkMode(t, indexName, 0600)
+}
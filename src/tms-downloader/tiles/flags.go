@@ -0,0 +1,115 @@
+package tiles
+
+import (
+	"flag"
+	"time"
+)
+
+// RegisterFlags ties the flags of the command line to the fields
+// of options, setting the fields to the defaults of the flags.
+// Usage is left to the command.
+func (options *Options) RegisterFlags(flags *flag.FlagSet) {
+	flags.StringVar(&options.URL, "url", "", "")
+	flags.Var(&options.Zooms, "zooms", "")
+	flags.Var(&options.Bbox, "bbox", "")
+	flags.IntVar(&options.WaitTime, "wait", 1000, "")
+	flags.IntVar(&options.WaitJitter, "wait-jitter", 0, "")
+	flags.IntVar(&options.WaitJitter, "jitter", 0, "")
+	flags.StringVar(&options.DumpGeoJSON, "dump-geojson", "", "")
+	flags.BoolVar(&options.CheckSeams, "check-seams", false, "")
+	flags.StringVar(&options.HTTPCache, "http-cache", "", "")
+	flags.Var(&options.StripQuery, "strip-query", "")
+	flags.StringVar(&options.OnTile, "on-tile", "", "")
+	flags.IntVar(&options.OnTileJobs, "on-tile-jobs", 4, "")
+	flags.BoolVar(&options.OnTileFatal, "on-tile-fatal", false, "")
+	flags.StringVar(&options.ReportMissing, "report-missing", "", "")
+	flags.BoolVar(&options.SidecarJSON, "sidecar-json", false, "")
+	flags.IntVar(&options.Reproject, "reproject", 0, "")
+	flags.IntVar(&options.RetryPasses, "retry-passes", 0, "")
+	flags.IntVar(&options.RetryPassDelay, "retry-pass-delay", 10, "")
+	flags.IntVar(&options.Sample, "sample", 0, "")
+	flags.StringVar(&options.DataURIJSON, "datauri-json", "", "")
+	flags.Var(&options.SessionHeaders, "session-header", "")
+	flags.BoolVar(&options.Cookies, "cookies", false, "")
+	flags.StringVar(&options.OutputLayout, "output-layout", "xyz", "")
+	flags.StringVar(&options.ProgressFile, "progress-file", "", "")
+	flags.Var(&options.UserAgents, "user-agent", "")
+	flags.StringVar(&options.UserAgentFile, "user-agent-file", "", "")
+	flags.BoolVar(&options.RandomUserAgent, "user-agent-random", false, "")
+	flags.BoolVar(&options.Benchmark, "benchmark", false, "")
+	flags.IntVar(&options.BenchmarkSample, "benchmark-sample", 32, "")
+	flags.StringVar(&options.IndexDB, "index-db", "", "")
+	flags.IntVar(&options.RetryDNS, "retry-dns", 2, "")
+	flags.IntVar(&options.LimitPerZoom, "limit-per-zoom", 0, "")
+	flags.StringVar(&options.ContentAddressed, "content-addressed", "", "")
+	flags.StringVar(&options.RequestTemplateFile, "request-template", "", "")
+	flags.IntVar(&options.MinAvgSize, "min-avg-size", 0, "")
+	flags.IntVar(&options.MaxAvgSize, "max-avg-size", 0, "")
+	flags.IntVar(&options.AvgSizeAfter, "avg-size-after", 200, "")
+	flags.BoolVar(&options.CORSCheck, "cors-check", false, "")
+	flags.StringVar(&options.CORSOrigin, "cors-origin", "http://localhost", "")
+	flags.DurationVar(&options.MaxAge, "max-age", 0, "")
+	flags.Var(&options.SplitOutput, "split-output", "")
+	flags.StringVar(&options.NotifyURL, "notify-url", "", "")
+	flags.Float64Var(&options.Brightness, "brightness", 0, "")
+	flags.Float64Var(&options.Gamma, "gamma", 1, "")
+	flags.StringVar(&options.Single, "single", "", "")
+	flags.BoolVar(&options.Stdout, "stdout", false, "")
+	flags.BoolVar(&options.CoverageReport, "coverage-report", false, "")
+	flags.BoolVar(&options.CoverageGrid, "coverage-grid", false, "")
+	flags.Float64Var(&options.ZoomWeight, "zoom-weighted-rate", 1, "")
+	flags.Var(&options.Asserts, "assert", "")
+	flags.StringVar(&options.OutputDir, "output", ".", "")
+	flags.StringVar(&options.Scheme, "scheme", "", "")
+	flags.StringVar(&options.Format, "format", "", "")
+	flags.IntVar(&options.Retries, "retries", 3, "")
+	flags.DurationVar(&options.RetryBackoff, "retry-backoff", 500*time.Millisecond, "")
+	flags.IntVar(&options.Concurrency, "concurrency", 1, "")
+	flags.BoolVar(&options.ConcurrencyPerZoomAuto, "concurrency-per-zoom-auto", false, "")
+	flags.BoolVar(&options.Resume, "resume", false, "")
+	flags.StringVar(&options.Username, "username", "", "")
+	flags.StringVar(&options.Password, "password", "", "")
+	flags.Var(&options.Headers, "header", "")
+	flags.StringVar(&options.MBTiles, "mbtiles", "", "")
+	flags.BoolVar(&options.MergeMBTiles, "merge-into-existing-mbtiles", false, "")
+	flags.BoolVar(&options.DryRun, "dry-run", false, "")
+	flags.IntVar(&options.MaxTiles, "max-tiles", 1000000, "")
+	flags.BoolVar(&options.Force, "force", false, "")
+	flags.StringVar(&options.OutputFormat, "output-format", "text", "")
+	flags.StringVar(&options.FailuresFile, "failures-file", "", "")
+	flags.StringVar(&options.TilesFile, "tiles-file", "", "")
+	flags.DurationVar(&options.MaxRetryAfter, "max-retry-after", time.Minute, "")
+	flags.DurationVar(&options.Timeout, "timeout", 30*time.Second, "")
+	flags.Var(&options.Subdomains, "subdomains", "")
+	flags.IntVar(&options.TileSize, "tile-size", 256, "")
+	flags.IntVar(&options.SRS, "srs", 3857, "")
+	flags.StringVar(&options.Config, "config", "", "")
+	flags.BoolVar(&options.SkipEmpty, "skip-empty", false, "")
+	flags.Var(&options.SkipHashes, "skip-hash", "")
+	flags.BoolVar(&options.Verify, "verify", false, "")
+	flags.BoolVar(&options.Leaflet, "leaflet", false, "")
+	flags.StringVar(&options.LogLevel, "log-level", "info", "")
+	flags.StringVar(&options.NameTemplate, "name-template", DefaultNameTemplate, "")
+	flags.BoolVar(&options.NoDecompress, "no-decompress", false, "")
+	flags.Float64Var(&options.RPS, "rps", 0, "")
+	flags.StringVar(&options.Manifest, "manifest", "", "")
+	flags.StringVar(&options.BboxFile, "bbox-file", "", "")
+	flags.IntVar(&options.MaxConnsPerHost, "max-conns-per-host", 0, "")
+	flags.BoolVar(&options.Overwrite, "overwrite", false, "")
+	flags.Var(&options.FileMode, "file-mode", "")
+	flags.BoolVar(&options.WMTS, "wmts", false, "")
+	flags.StringVar(&options.WMTSLayer, "wmts-layer", "", "")
+	flags.StringVar(&options.WMTSStyle, "wmts-style", "default", "")
+	flags.StringVar(&options.WMTSMatrixSet, "wmts-matrix-set", "", "")
+	flags.BoolVar(&options.Retina, "retina", false, "")
+	flags.StringVar(&options.Checksums, "checksums", "", "")
+	flags.StringVar(&options.Zip, "zip", "", "")
+	flags.BoolVar(&options.FailFast, "fail-fast", false, "")
+	flags.IntVar(&options.MaxConsecutiveFailures, "max-consecutive-failures", 0, "")
+	flags.StringVar(&options.TileJSON, "tilejson", "", "")
+	flags.BoolVar(&options.WorldFiles, "world-files", false, "")
+	flags.BoolVar(&options.VRT, "vrt", false, "")
+	flags.BoolVar(&options.VerboseErrors, "verbose-errors", false, "")
+	flags.StringVar(&options.Proxy, "proxy", "", "")
+	flags.BoolVar(&options.Help, "help", false, "")
+}
@@ -0,0 +1,39 @@
+package tiles
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Headers stores HTTP headers sent with each request.
+type Headers http.Header
+
+// String is the method to format the flag's value, part of the flag.Value interface.
+// The String method's output will be used in diagnostics.
+func (headers *Headers) String() string {
+	return fmt.Sprint(*headers)
+}
+
+// Set is the method to set the flag value, part of the flag.Value interface.
+// Each use of the flag adds one header in "Name: value" format.
+func (headers *Headers) Set(value string) error {
+	name, val, ok := strings.Cut(value, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("Malformed header %q, use \"Name: value\"", value)
+	}
+	if *headers == nil {
+		*headers = Headers{}
+	}
+	http.Header(*headers).Add(name, strings.TrimSpace(val))
+	return nil
+}
+
+// apply sets the headers on the request,
+// replacing headers of the same name.
+func (headers Headers) apply(req *http.Request) {
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+}
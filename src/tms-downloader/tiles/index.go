@@ -0,0 +1,81 @@
+package tiles
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"path"
+	"time"
+
+	// Registers the sqlite3 database/sql driver.
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Index is an SQLite database recording the saved tiles of a
+// z/x/y tree, their sizes and SHA-256 hashes. It answers coverage
+// and size queries without walking the tree, for example:
+//
+//	SELECT z, COUNT(*), SUM(size) FROM tiles GROUP BY z;
+type Index struct {
+	db     *sql.DB
+	insert *sql.Stmt
+}
+
+// OpenIndex opens the index in the file, creating it
+// with the mode if needed.
+func OpenIndex(filename string, mode FileMode) (*Index, error) {
+	if err := mode.createDB(filename); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return nil, err
+	}
+	// Concurrent downloads share one connection, so that
+	// writes do not fail on a locked database.
+	db.SetMaxOpenConns(1)
+	for _, statement := range []string{
+		`PRAGMA journal_mode=WAL`,
+		`PRAGMA synchronous=NORMAL`,
+		`CREATE TABLE IF NOT EXISTS tiles (
+			z INTEGER NOT NULL,
+			x INTEGER NOT NULL,
+			y INTEGER NOT NULL,
+			path TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			sha256 TEXT NOT NULL,
+			saved TIMESTAMP NOT NULL,
+			PRIMARY KEY (z, x, y)
+		)`,
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	insert, err := db.Prepare(`INSERT OR REPLACE INTO tiles (z, x, y, path, size, sha256, saved) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Index{db: db, insert: insert}, nil
+}
+
+// Add records the saved tile, replacing an earlier record of it.
+func (index *Index) Add(tile *Tile) error {
+	sum := sha256.Sum256(tile.Content)
+	_, err := index.insert.Exec(
+		tile.TileID.Z, tile.TileID.X, tile.TileID.Y,
+		path.Join(tile.Path, tile.Name),
+		len(tile.Content),
+		hex.EncodeToString(sum[:]),
+		time.Now(),
+	)
+	return err
+}
+
+// Close closes the index database.
+func (index *Index) Close() error {
+	index.insert.Close()
+	return index.db.Close()
+}
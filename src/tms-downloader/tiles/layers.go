@@ -0,0 +1,126 @@
+package tiles
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Layer is one of several tile sources downloaded over
+// the same bbox and zooms in a run (see Options.Layers).
+type Layer struct {
+	// Name of the layer, the subdirectory of the output
+	// directory its tiles are saved in.
+	Name string
+	// URL template of the layer's tiles.
+	URL string
+	// Format overrides Options.Format, if set.
+	Format string
+	// MBTiles is a file the tiles are written into
+	// instead of the subdirectory, if set.
+	MBTiles string
+}
+
+// ReadConfigLayers reads the layers section of a config file
+// (see ReadConfigFile), a list of layers with the name, url
+// and optional format and mbtiles of each, e.g.
+//
+//	layers:
+//	  - name: roads
+//	    url: https://roads.example.com/{z}/{x}/{y}.png
+//	  - name: labels
+//	    url: https://labels.example.com/{z}/{x}/{y}.pbf
+//	    format: pbf
+//	    mbtiles: labels.mbtiles
+//
+// A file without the section has no layers.
+func ReadConfigLayers(filename string) ([]Layer, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var config struct {
+		Layers []map[string]string `yaml:"layers"`
+	}
+	// Other options are read by ReadConfigFile.
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("Config file %v: %v", filename, err)
+	}
+	layers := make([]Layer, len(config.Layers))
+	for i, values := range config.Layers {
+		for name, value := range values {
+			switch name {
+			case "name":
+				layers[i].Name = value
+			case "url":
+				layers[i].URL = value
+			case "format":
+				layers[i].Format = value
+			case "mbtiles":
+				layers[i].MBTiles = value
+			default:
+				return nil, fmt.Errorf("Config file %v: unknown option %q of layer %v", filename, name, i+1)
+			}
+		}
+	}
+	return layers, nil
+}
+
+// ForLayer returns the options of downloading the layer: its
+// url and format, its MBTiles or the layer's subdirectory of
+// OutputDir, and a Session of its own. The rest, like
+// RateLimiter, is shared.
+func (options Options) ForLayer(layer Layer) Options {
+	options.Layers = nil
+	options.Session = NewSession()
+	options.URL = layer.URL
+	if layer.Format != "" {
+		options.Format = layer.Format
+	}
+	if layer.MBTiles != "" {
+		options.MBTiles = layer.MBTiles
+	} else {
+		options.OutputDir = path.Join(options.OutputDir, layer.Name)
+	}
+	return options
+}
+
+// checkLayers validates Layers: each layer must have a name
+// of its own and options valid for downloading it (see
+// ForLayer). Layers are only downloaded, each into its
+// subdirectory or MBTiles, without outputs of the whole run.
+func (options *Options) checkLayers() error {
+	switch {
+	case options.URL != "" || options.TileJSON != "" || options.WMTS || options.RequestTemplateFile != "":
+		return errors.New("Layers have urls of their own, they can not be used with a url, TileJSON, WMTS or request template")
+	case countSet(options.DataURIJSON, options.ContentAddressed, options.MBTiles, options.Zip, options.IndexDB, options.Manifest) > 0 || len(options.SplitOutput) > 0:
+		return errors.New("Layers are saved in subdirectories or MBTiles of their own, they can not be used with other outputs")
+	case countSet(options.FailuresFile, options.ReportMissing, options.DumpGeoJSON) > 0 || options.Leaflet || options.VRT || options.CoverageReport || options.CoverageGrid:
+		return errors.New("Layers can not be used with reports or previews of the tiles")
+	case options.Single != "" || len(options.Asserts) > 0 || options.CheckSeams || options.CORSCheck || options.Benchmark:
+		return errors.New("Layers can only be downloaded")
+	}
+	names := make(map[string]bool)
+	mbtiles := make(map[string]bool)
+	for _, layer := range options.Layers {
+		switch {
+		case layer.Name == "" || layer.Name == "." || layer.Name == ".." || strings.ContainsRune(layer.Name, '/'):
+			return fmt.Errorf("Layer name %q is not a directory name", layer.Name)
+		case names[layer.Name]:
+			return fmt.Errorf("Layer %v is given twice", layer.Name)
+		case layer.MBTiles != "" && mbtiles[layer.MBTiles]:
+			return fmt.Errorf("Layer %v: MBTiles file %v is of another layer", layer.Name, layer.MBTiles)
+		}
+		names[layer.Name] = true
+		mbtiles[layer.MBTiles] = true
+		layerOptions := options.ForLayer(layer)
+		if err := layerOptions.ValidateOptions(); err != nil {
+			return fmt.Errorf("Layer %v: %v", layer.Name, err)
+		}
+	}
+	return nil
+}
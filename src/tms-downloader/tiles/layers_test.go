@@ -0,0 +1,101 @@
+package tiles
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestReadConfigLayers(t *testing.T) {
+	dir := t.TempDir()
+	filename := path.Join(dir, "config.yaml")
+	content := `zooms: 3-10
+layers:
+  - name: roads
+    url: https://roads.example.com/{z}/{x}/{y}.png
+  - name: labels
+    url: https://labels.example.com/{z}/{x}/{y}.pbf
+    format: pbf
+    mbtiles: labels.mbtiles
+`
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	layers, err := ReadConfigLayers(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Layer{
+		{Name: "roads", URL: "https://roads.example.com/{z}/{x}/{y}.png"},
+		{Name: "labels", URL: "https://labels.example.com/{z}/{x}/{y}.pbf", Format: "pbf", MBTiles: "labels.mbtiles"},
+	}
+	if !reflect.DeepEqual(layers, want) {
+		t.Errorf("got %+v, want %+v", layers, want)
+	}
+	config, err := ReadConfigFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, map[string][]string{"zooms": {"3-10"}}) {
+		t.Errorf("got %v, want the zooms only", config)
+	}
+
+	unknown := path.Join(dir, "unknown.yaml")
+	if err := os.WriteFile(unknown, []byte("layers:\n  - name: roads\n    bbox: 1,2,3,4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadConfigLayers(unknown); err == nil {
+		t.Error("unknown option of a layer was read")
+	}
+}
+
+func TestCheckLayers(t *testing.T) {
+	roads := Layer{Name: "roads", URL: "https://roads.example.com/{z}/{x}/{y}.png"}
+	labels := Layer{Name: "labels", URL: "https://labels.example.com/{z}/{x}/{y}.pbf", MBTiles: "labels.mbtiles"}
+	tests := []struct {
+		name   string
+		layers []Layer
+		change func(options *Options)
+		ok     bool
+	}{
+		{"layers", []Layer{roads, labels}, nil, true},
+		{"name twice", []Layer{roads, roads}, nil, false},
+		{"no name", []Layer{{URL: roads.URL}}, nil, false},
+		{"path as name", []Layer{{Name: "../roads", URL: roads.URL}}, nil, false},
+		{"no url", []Layer{{Name: "roads"}}, nil, false},
+		{"invalid url", []Layer{{Name: "roads", URL: "https://roads.example.com/"}}, nil, false},
+		{"mbtiles twice", []Layer{labels, {Name: "other", URL: labels.URL, MBTiles: labels.MBTiles}}, nil, false},
+		{"url", []Layer{roads}, func(options *Options) { options.URL = roads.URL }, false},
+		{"zip", []Layer{roads}, func(options *Options) { options.Zip = "tiles.zip" }, false},
+		{"leaflet", []Layer{roads}, func(options *Options) { options.Leaflet = true }, false},
+		{"max age with mbtiles", []Layer{labels}, func(options *Options) { options.MaxAge = 1 }, false},
+	}
+	for _, test := range tests {
+		options := Options{
+			Zooms:       Zooms{3},
+			Bbox:        Bbox{Left: 24, Bottom: 60, Right: 25, Top: 61},
+			TileSize:    256,
+			Concurrency: 1,
+			Layers:      test.layers,
+		}
+		if test.change != nil {
+			test.change(&options)
+		}
+		if err := options.ValidateOptions(); (err == nil) != test.ok {
+			t.Errorf("%v: got error %v, want ok %v", test.name, err, test.ok)
+		}
+	}
+}
+
+func TestForLayer(t *testing.T) {
+	options := Options{OutputDir: "tiles", Format: "png", Layers: []Layer{{Name: "roads"}}}
+	roads := options.ForLayer(Layer{Name: "roads", URL: "https://roads.example.com/{z}/{x}/{y}.png"})
+	if roads.OutputDir != "tiles/roads" || roads.URL != "https://roads.example.com/{z}/{x}/{y}.png" || roads.Format != "png" || roads.Layers != nil {
+		t.Errorf("got %+v", roads)
+	}
+	labels := options.ForLayer(Layer{Name: "labels", Format: "pbf", MBTiles: "labels.mbtiles"})
+	if labels.OutputDir != "tiles" || labels.MBTiles != "labels.mbtiles" || labels.Format != "pbf" {
+		t.Errorf("got %+v", labels)
+	}
+}
@@ -0,0 +1,312 @@
+package tiles
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Luqqk/wms-tiles-downloader/pkg/mercantile"
+)
+
+// MBTiles is an MBTiles (https://github.com/mapbox/mbtiles-spec)
+// SQLite container the tiles are written into instead of the
+// z/x/y tree. Rows of the tiles table follow the TMS y axis.
+type MBTiles struct {
+	db      *sql.DB
+	insert  *sql.Stmt
+	has     *sql.Stmt
+	options Options
+	// written counts the tiles added, to
+	// checkpoint every checkpointInterval.
+	written int
+	// Zoom range, bounds and format of the
+	// tiles, those of an existing container
+	// merged in, written to metadata on Close.
+	mu      sync.Mutex
+	minZoom int
+	maxZoom int
+	bounds  Bbox
+	format  string
+}
+
+// checkpointInterval is the number of tiles after which the
+// write-ahead log is checkpointed and the metadata written, so
+// that a crash leaves a complete container of the added tiles.
+const checkpointInterval = 1000
+
+// OpenMBTiles opens the container in the file, creating it if needed.
+// It is written in WAL mode, each tile committed when added.
+// The file is created with options.FileMode.
+// An existing container is only added to with options.MergeMBTiles
+// or options.Resume, and if its schema is that of checkSchema. Its
+// tiles are replaced when added again, its zoom range and bounds
+// extended by those of the tiles added.
+func OpenMBTiles(filename string, options Options) (*MBTiles, error) {
+	info, err := os.Stat(filename)
+	exists := err == nil && info.Size() > 0
+	if exists && !options.MergeMBTiles && !options.Resume {
+		return nil, fmt.Errorf("MBTiles file %v exists, it is only added to when merging or resuming", filename)
+	}
+	if err := options.FileMode.createDB(filename); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if exists {
+		if err := checkSchema(db); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("MBTiles file %v can not be added to: %w", filename, err)
+		}
+	}
+	for _, statement := range []string{
+		`PRAGMA journal_mode=WAL`,
+		`PRAGMA synchronous=NORMAL`,
+		`CREATE TABLE IF NOT EXISTS metadata (name TEXT, value TEXT)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS metadata_name ON metadata (name)`,
+		`CREATE TABLE IF NOT EXISTS tiles (
+			zoom_level INTEGER,
+			tile_column INTEGER,
+			tile_row INTEGER,
+			tile_data BLOB
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS tile_index ON tiles (zoom_level, tile_column, tile_row)`,
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, storageError(err)
+		}
+	}
+	insert, err := db.Prepare(`INSERT OR REPLACE INTO tiles (zoom_level, tile_column, tile_row, tile_data) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	has, err := db.Prepare(`SELECT COUNT(*) FROM tiles WHERE zoom_level = ? AND tile_column = ? AND tile_row = ?`)
+	if err != nil {
+		insert.Close()
+		db.Close()
+		return nil, err
+	}
+	mbtiles := &MBTiles{db: db, insert: insert, has: has, options: options, minZoom: -1, bounds: options.Bbox}
+	err = mbtiles.readMetadata()
+	if err == nil && options.Format != "" && mbtiles.format != "" && options.Format != mbtiles.format {
+		err = fmt.Errorf("MBTiles file %v holds %v tiles, not %v", filename, mbtiles.format, options.Format)
+	}
+	if err != nil {
+		insert.Close()
+		has.Close()
+		db.Close()
+		return nil, err
+	}
+	return mbtiles, nil
+}
+
+// mbtilesColumns are the columns of the
+// tables written into, by table name.
+var mbtilesColumns = map[string][]string{
+	"metadata": {"name", "value"},
+	"tiles":    {"zoom_level", "tile_column", "tile_row", "tile_data"},
+}
+
+// checkSchema checks that the tables of an existing container
+// are those written into: tables, not views like those of
+// deduplicating containers, with the columns of mbtilesColumns.
+// Files which are not SQLite databases fail the check too.
+func checkSchema(db *sql.DB) error {
+	for table, columns := range mbtilesColumns {
+		var kind string
+		err := db.QueryRow(`SELECT type FROM sqlite_master WHERE name = ?`, table).Scan(&kind)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("no %v table", table)
+		}
+		if err != nil {
+			return storageError(err)
+		}
+		if kind != "table" {
+			return fmt.Errorf("%v is a %v, not a table", table, kind)
+		}
+		rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+		if err != nil {
+			return storageError(err)
+		}
+		found := make(map[string]bool)
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return err
+			}
+			found[name] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, column := range columns {
+			if !found[column] {
+				return fmt.Errorf("no %v column in %v table", column, table)
+			}
+		}
+	}
+	return nil
+}
+
+// readMetadata merges the zoom range and bounds stored
+// in the metadata of an existing container. Values which
+// can not be parsed are replaced.
+func (mbtiles *MBTiles) readMetadata() error {
+	rows, err := mbtiles.db.Query(`SELECT name, value FROM metadata WHERE name IN ('minzoom', 'maxzoom', 'bounds', 'format')`)
+	if err != nil {
+		return storageError(err)
+	}
+	defer rows.Close()
+	stored := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return err
+		}
+		stored[name] = value
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	minZoom, minErr := strconv.Atoi(stored["minzoom"])
+	maxZoom, maxErr := strconv.Atoi(stored["maxzoom"])
+	if minErr == nil && maxErr == nil && minZoom >= 0 && minZoom <= maxZoom {
+		mbtiles.minZoom, mbtiles.maxZoom = minZoom, maxZoom
+	}
+	var bounds Bbox
+	if bounds.Set(stored["bounds"]) == nil && bounds.check() == nil {
+		if mbtiles.bounds == (Bbox{}) {
+			mbtiles.bounds = bounds
+		} else {
+			mbtiles.bounds = Bbox{
+				Left:   math.Min(mbtiles.bounds.Left, bounds.Left),
+				Bottom: math.Min(mbtiles.bounds.Bottom, bounds.Bottom),
+				Right:  math.Max(mbtiles.bounds.Right, bounds.Right),
+				Top:    math.Max(mbtiles.bounds.Top, bounds.Top),
+			}
+		}
+	}
+	mbtiles.format = stored["format"]
+	return nil
+}
+
+// Has tells if the container holds the tile, e.g. from an
+// interrupted run. Tiles which can not be looked up are not.
+func (mbtiles *MBTiles) Has(tileID mercantile.TileID) bool {
+	var count int
+	err := mbtiles.has.QueryRow(tileID.Z, tileID.X, FlipY(tileID.Y, tileID.Z)).Scan(&count)
+	return err == nil && count > 0
+}
+
+// Write inserts the tile into the container, every
+// checkpointInterval tiles checkpointing it. Tiles of
+// other formats than those in the container are refused.
+func (mbtiles *MBTiles) Write(tile *Tile) error {
+	z := tile.TileID.Z
+	format := strings.TrimPrefix(path.Ext(tile.Name), ".")
+	if stored := mbtiles.storedFormat(); stored != "" && format != stored {
+		return fmt.Errorf("Tile %v is %v, the MBTiles container holds %v tiles", tile.Name, format, stored)
+	}
+	if _, err := mbtiles.insert.Exec(z, tile.TileID.X, FlipY(tile.TileID.Y, z), tile.Content); err != nil {
+		return storageError(err)
+	}
+	if mbtiles.count()%checkpointInterval == 0 {
+		if err := mbtiles.checkpoint("PASSIVE"); err != nil {
+			return err
+		}
+	}
+	mbtiles.mu.Lock()
+	defer mbtiles.mu.Unlock()
+	if mbtiles.minZoom < 0 || z < mbtiles.minZoom {
+		mbtiles.minZoom = z
+	}
+	if z > mbtiles.maxZoom {
+		mbtiles.maxZoom = z
+	}
+	if mbtiles.format == "" {
+		mbtiles.format = format
+	}
+	return nil
+}
+
+// storedFormat returns the format of the tiles
+// in the container, empty if none is known yet.
+func (mbtiles *MBTiles) storedFormat() string {
+	mbtiles.mu.Lock()
+	defer mbtiles.mu.Unlock()
+	return mbtiles.format
+}
+
+// count counts an added tile, returning the number
+// of tiles added.
+func (mbtiles *MBTiles) count() int {
+	mbtiles.mu.Lock()
+	defer mbtiles.mu.Unlock()
+	mbtiles.written++
+	return mbtiles.written
+}
+
+// checkpoint writes the metadata and checkpoints the
+// write-ahead log into the database in mode, PASSIVE
+// or TRUNCATE.
+func (mbtiles *MBTiles) checkpoint(mode string) error {
+	for name, value := range mbtiles.metadata() {
+		if value == "" {
+			continue
+		}
+		if _, err := mbtiles.db.Exec(`INSERT OR REPLACE INTO metadata (name, value) VALUES (?, ?)`, name, value); err != nil {
+			return storageError(err)
+		}
+	}
+	_, err := mbtiles.db.Exec(`PRAGMA wal_checkpoint(` + mode + `)`)
+	return storageError(err)
+}
+
+// metadata returns the metadata rows of the container.
+// Bounds are those of the bbox, if one was given, and
+// of the existing container.
+func (mbtiles *MBTiles) metadata() map[string]string {
+	mbtiles.mu.Lock()
+	defer mbtiles.mu.Unlock()
+	// Named after the host, as the url
+	// may contain credentials or keys.
+	name := mbtiles.options.URL
+	if u, err := url.Parse(name); err == nil && u.Host != "" {
+		name = u.Host
+	}
+	metadata := map[string]string{
+		"name":   name,
+		"format": mbtiles.format,
+	}
+	if mbtiles.minZoom >= 0 {
+		metadata["minzoom"] = strconv.Itoa(mbtiles.minZoom)
+		metadata["maxzoom"] = strconv.Itoa(mbtiles.maxZoom)
+	}
+	if bbox := mbtiles.bounds; bbox != (Bbox{}) {
+		metadata["bounds"] = fmt.Sprintf("%v,%v,%v,%v", bbox.Left, bbox.Bottom, bbox.Right, bbox.Top)
+	}
+	return metadata
+}
+
+// Close writes the metadata, checkpoints the write-ahead
+// log, leaving none, and closes the container.
+func (mbtiles *MBTiles) Close() error {
+	defer mbtiles.db.Close()
+	mbtiles.insert.Close()
+	mbtiles.has.Close()
+	return mbtiles.checkpoint("TRUNCATE")
+}
@@ -0,0 +1,183 @@
+package tiles
+
+import (
+	"context"
+	"database/sql"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Luqqk/wms-tiles-downloader/pkg/mercantile"
+)
+
+func TestMBTilesMergesMetadata(t *testing.T) {
+	filename := path.Join(t.TempDir(), "tiles.mbtiles")
+	runs := []struct {
+		bbox   Bbox
+		tileID mercantile.TileID
+	}{
+		{Bbox{Left: 24, Bottom: 60, Right: 25, Top: 61}, mercantile.TileID{X: 4, Y: 2, Z: 3}},
+		{Bbox{Left: 22, Bottom: 59.5, Right: 24.5, Top: 60.5}, mercantile.TileID{X: 17, Y: 9, Z: 5}},
+		// Tiles of the zoom range added again.
+		{Bbox{Left: 24, Bottom: 60, Right: 25, Top: 61}, mercantile.TileID{X: 4, Y: 2, Z: 3}},
+	}
+	for _, run := range runs {
+		mbtiles, err := OpenMBTiles(filename, Options{URL: "http://tiles.example/{z}/{x}/{y}.png", Bbox: run.bbox, MergeMBTiles: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := mbtiles.Write(&Tile{TileID: run.tileID, Name: "0.png", Content: []byte("tile")}); err != nil {
+			t.Fatal(err)
+		}
+		if err := mbtiles.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	want := map[string]string{
+		"name":    "tiles.example",
+		"format":  "png",
+		"minzoom": "3",
+		"maxzoom": "5",
+		"bounds":  "22,59.5,25,61",
+	}
+	for name, value := range want {
+		var got string
+		if err := db.QueryRow(`SELECT value FROM metadata WHERE name = ?`, name).Scan(&got); err != nil {
+			t.Errorf("%v: %v", name, err)
+		} else if got != value {
+			t.Errorf("%v is %q, want %q", name, got, value)
+		}
+	}
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM tiles`).Scan(&count); err != nil || count != 2 {
+		t.Errorf("got %v tiles and error %v, want 2", count, err)
+	}
+}
+
+func TestResumeIntoMBTiles(t *testing.T) {
+	var requests atomic.Int32
+	content := pngTile(t, color.White)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(content)
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	options := Options{
+		URL:       server.URL + "/{z}/{x}/{y}.png",
+		Zooms:     Zooms{0},
+		Bbox:      Bbox{Left: -180, Bottom: -85, Right: 180, Top: 85},
+		OutputDir: dir,
+		MBTiles:   path.Join(dir, "tiles.mbtiles"),
+	}
+	if _, err := Download(context.Background(), options); err != nil {
+		t.Fatal(err)
+	}
+
+	// The tile of zoom 0 is in the container, not in the z/x/y tree.
+	requests.Store(0)
+	options.Zooms = Zooms{0, 1}
+	options.Resume = true
+	jobs, err := Download(context.Background(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs.Skipped != 1 || jobs.Succeeded != 4 || requests.Load() != 4 {
+		t.Errorf("got %v skipped and %v succeeded tiles in %v requests, want 1, 4 and 4", jobs.Skipped, jobs.Succeeded, requests.Load())
+	}
+
+	db, err := sql.Open("sqlite3", options.MBTiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var mode string
+	if err := db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("got journal mode %q and error %v, want wal", mode, err)
+	}
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM tiles`).Scan(&count); err != nil || count != 5 {
+		t.Errorf("got %v tiles and error %v, want 5", count, err)
+	}
+}
+
+func TestMBTilesRefusesExistingFiles(t *testing.T) {
+	dir := t.TempDir()
+	create := func(name string, statements ...string) string {
+		filename := path.Join(dir, name)
+		db, err := sql.Open("sqlite3", filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		for _, statement := range statements {
+			if _, err := db.Exec(statement); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return filename
+	}
+	valid := create("valid.mbtiles",
+		`CREATE TABLE metadata (name TEXT, value TEXT)`,
+		`CREATE TABLE tiles (zoom_level INTEGER, tile_column INTEGER, tile_row INTEGER, tile_data BLOB)`,
+		`INSERT INTO metadata VALUES ('format', 'jpg')`)
+	deduplicated := create("deduplicated.mbtiles",
+		`CREATE TABLE metadata (name TEXT, value TEXT)`,
+		`CREATE TABLE map (zoom_level INTEGER, tile_column INTEGER, tile_row INTEGER, tile_id TEXT)`,
+		`CREATE TABLE images (tile_id TEXT, tile_data BLOB)`,
+		`CREATE VIEW tiles AS SELECT zoom_level, tile_column, tile_row, tile_data FROM map JOIN images USING (tile_id)`)
+	columns := create("columns.mbtiles",
+		`CREATE TABLE metadata (name TEXT, value TEXT)`,
+		`CREATE TABLE tiles (z INTEGER, x INTEGER, y INTEGER, data BLOB)`)
+	other := path.Join(dir, "other.mbtiles")
+	if err := os.WriteFile(other, []byte("not a database, but long enough to be a header of one"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		filename string
+		options  Options
+		ok       bool
+	}{
+		{valid, Options{}, false},
+		{valid, Options{MergeMBTiles: true}, true},
+		{valid, Options{Resume: true}, true},
+		{valid, Options{MergeMBTiles: true, Format: "png"}, false},
+		{deduplicated, Options{MergeMBTiles: true}, false},
+		{columns, Options{MergeMBTiles: true}, false},
+		{other, Options{MergeMBTiles: true}, false},
+		{path.Join(dir, "new.mbtiles"), Options{}, true},
+	}
+	for _, test := range tests {
+		mbtiles, err := OpenMBTiles(test.filename, test.options)
+		if (err == nil) != test.ok {
+			t.Errorf("%v with %+v: got error %v, want ok %v", path.Base(test.filename), test.options, err, test.ok)
+		}
+		if err == nil {
+			mbtiles.Close()
+		}
+	}
+
+	mbtiles, err := OpenMBTiles(valid, Options{MergeMBTiles: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mbtiles.Close()
+	if err := mbtiles.Write(&Tile{TileID: mercantile.TileID{X: 0, Y: 0, Z: 0}, Name: "0.png", Content: []byte("tile")}); err == nil {
+		t.Error("png tile was added to a container of jpg tiles")
+	}
+	if err := mbtiles.Write(&Tile{TileID: mercantile.TileID{X: 0, Y: 0, Z: 0}, Name: "0.jpg", Content: []byte("tile")}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,55 @@
+package tiles
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// DefaultNameTemplate saves tiles in the z/x/y tree.
+const DefaultNameTemplate = "{z}/{x}/{y}{r}.{ext}"
+
+var reNamePlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// checkNameTemplate tells if template names each tile
+// with a distinct relative path.
+func checkNameTemplate(template string) error {
+	for _, placeholder := range []string{"{z}", "{x}", "{y}"} {
+		if !strings.Contains(template, placeholder) {
+			return fmt.Errorf("Name template %q is missing %v", template, placeholder)
+		}
+	}
+	for _, placeholder := range reNamePlaceholder.FindAllString(template, -1) {
+		switch placeholder {
+		case "{z}", "{x}", "{y}", "{r}", "{ext}":
+		default:
+			return fmt.Errorf("Unknown placeholder %v in name template %q, use {z}, {x}, {y}, {r} and {ext}", placeholder, template)
+		}
+	}
+	if path.IsAbs(template) || strings.HasSuffix(template, "/") {
+		return fmt.Errorf("Name template %q must be a relative file path", template)
+	}
+	for _, element := range strings.Split(template, "/") {
+		if element == ".." {
+			return fmt.Errorf("Name template %q must not point outside of the output directory", template)
+		}
+	}
+	return nil
+}
+
+// expandNameTemplate returns the path of the tile named by
+// template, with y in the output layout, retina suffix for
+// {r} and extension including the dot.
+func expandNameTemplate(template string, z, x, y int, retina string, extension string) string {
+	if template == "" {
+		template = DefaultNameTemplate
+	}
+	return strings.NewReplacer(
+		"{z}", fmt.Sprint(z),
+		"{x}", fmt.Sprint(x),
+		"{y}", fmt.Sprint(y),
+		"{r}", retina,
+		"{ext}", strings.TrimPrefix(extension, "."),
+	).Replace(template)
+}
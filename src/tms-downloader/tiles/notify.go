@@ -0,0 +1,65 @@
+package tiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Notification is the JSON payload posted by Notify.
+type Notification struct {
+	Status      string             `json:"status"`
+	Message     string             `json:"message,omitempty"`
+	Jobs        *JobStats          `json:"jobs"`
+	SuccessRate float64            `json:"success_rate"`
+	Config      NotificationConfig `json:"config"`
+}
+
+// NotificationConfig summarizes the options of the run.
+// Credentials and query of the url are left out, as
+// they may hold secrets like API keys.
+type NotificationConfig struct {
+	URL   string    `json:"url"`
+	Zooms []int     `json:"zooms"`
+	Bbox  []float64 `json:"bbox"`
+}
+
+// Notify posts the state of the run as JSON to the webhook
+// at notifyURL, with the client of tile requests. Status is
+// "completed" or "aborted", with the reason of aborting in
+// message.
+func Notify(notifyURL string, status string, message string, jobs *JobStats, options Options) error {
+	sourceURL := options.URL
+	if u, err := url.Parse(options.URL); err == nil {
+		u.User = nil
+		u.RawQuery = ""
+		sourceURL, _ = url.PathUnescape(u.String())
+	}
+	notification := Notification{
+		Status:  status,
+		Message: message,
+		Jobs:    jobs,
+		Config: NotificationConfig{
+			URL:   sourceURL,
+			Zooms: options.Zooms,
+			Bbox:  []float64{options.Bbox.Left, options.Bbox.Bottom, options.Bbox.Right, options.Bbox.Top},
+		},
+	}
+	if succeeded, skipped, failed := jobs.counts(); succeeded+skipped+failed > 0 {
+		notification.SuccessRate = float64(succeeded+skipped) / float64(succeeded+skipped+failed)
+	}
+	content, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient(options).Post(notifyURL, "application/json", bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Notification to %v failed: %v", notifyURL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package tiles
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// jobStatsJSON is the JSON form of JobStats.
+type jobStatsJSON struct {
+	All        int       `json:"all"`
+	Succeeded  int       `json:"succeeded"`
+	Skipped    int       `json:"skipped"`
+	Failed     int       `json:"failed"`
+	Start      time.Time `json:"start"`
+	DurationMs int64     `json:"duration_ms"`
+	// Zooms are the counts by zoom level.
+	Zooms map[int]zoomStat `json:"zooms"`
+}
+
+// MarshalJSON encodes current state of jobs,
+// along with time elapsed since Start.
+func (jobs *JobStats) MarshalJSON() ([]byte, error) {
+	succeeded, skipped, failed := jobs.counts()
+	zooms, _ := jobs.zoomCounts()
+	return json.Marshal(jobStatsJSON{
+		All:        jobs.All,
+		Succeeded:  succeeded,
+		Skipped:    skipped,
+		Failed:     failed,
+		Start:      jobs.Start,
+		DurationMs: int64(time.Since(jobs.Start) / time.Millisecond),
+		Zooms:      zooms,
+	})
+}
+
+// WriteProgress writes current state of jobs as JSON to the
+// file, replacing previous content, created with the mode. The
+// file may be a named pipe, its permissions are left as they are.
+// Opening does not block: without a reader on the pipe the
+// update is skipped.
+func (jobs *JobStats) WriteProgress(filename string, mode FileMode) error {
+	content, err := json.Marshal(jobs)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|syscall.O_NONBLOCK, mode.file())
+	if errors.Is(err, syscall.ENXIO) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(content, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+package tiles
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestJobStatsZooms(t *testing.T) {
+	jobs := &JobStats{All: 6}
+	jobs.AddSucceeded(3)
+	jobs.AddSkipped(3)
+	jobs.AddSucceeded(12)
+	jobs.AddFailed(19, 3)
+	// A failed tile retried successfully.
+	jobs.AddFailed(19, -1)
+	jobs.AddSucceeded(19)
+
+	if jobs.Succeeded != 3 || jobs.Skipped != 1 || jobs.Failed != 2 {
+		t.Errorf("got %v succeeded, %v skipped and %v failed jobs, want 3, 1 and 2", jobs.Succeeded, jobs.Skipped, jobs.Failed)
+	}
+	counts, zooms := jobs.zoomCounts()
+	if !reflect.DeepEqual(zooms, []int{3, 12, 19}) {
+		t.Errorf("got zooms %v, want 3, 12 and 19", zooms)
+	}
+	want := map[int]zoomStat{
+		3:  {Succeeded: 1, Skipped: 1},
+		12: {Succeeded: 1},
+		19: {Succeeded: 1, Failed: 2},
+	}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("got counts %v, want %v", counts, want)
+	}
+
+	content, err := json.Marshal(jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary jobStatsJSON
+	if err := json.Unmarshal(content, &summary); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(summary.Zooms, want) || summary.All != 6 || summary.Failed != 2 {
+		t.Errorf("got JSON summary %s", content)
+	}
+}
+
+func TestWriteProgressFIFOWithoutReader(t *testing.T) {
+	filename := path.Join(t.TempDir(), "progress")
+	if err := syscall.Mkfifo(filename, 0600); err != nil {
+		t.Skip("named pipes are not supported:", err)
+	}
+	jobs := &JobStats{All: 1}
+	done := make(chan error, 1)
+	go func() { done <- jobs.WriteProgress(filename, 0) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("got error %v, want the update skipped", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("WriteProgress blocked on a named pipe without reader")
+	}
+
+	// With a reader the update goes through.
+	reader, err := os.OpenFile(filename, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if err := jobs.WriteProgress(filename, 0); err != nil {
+		t.Fatal(err)
+	}
+	var summary jobStatsJSON
+	if err := json.NewDecoder(reader).Decode(&summary); err != nil || summary.All != 1 {
+		t.Errorf("read %+v and error %v from the pipe", summary, err)
+	}
+}
@@ -0,0 +1,42 @@
+package tiles
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter spaces requests evenly to a number of requests
+// per second. It is shared by all download workers and safe
+// for concurrent use.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewRateLimiter returns a limiter allowing rps requests
+// per second.
+func NewRateLimiter(rps float64) *RateLimiter {
+	return &RateLimiter{interval: time.Duration(float64(time.Second) / rps)}
+}
+
+// Wait blocks until the next request is allowed, or
+// returns the error of ctx if it is cancelled before.
+// The request takes weight times the interval of the
+// limiter, deferring the following one. A nil limiter
+// does not limit.
+func (limiter *RateLimiter) Wait(ctx context.Context, weight float64) error {
+	if limiter == nil {
+		return nil
+	}
+	limiter.mu.Lock()
+	now := time.Now()
+	at := limiter.next
+	if at.Before(now) {
+		at = now
+	}
+	limiter.next = at.Add(time.Duration(float64(limiter.interval) * weight))
+	limiter.mu.Unlock()
+	return sleep(ctx, at.Sub(now))
+}
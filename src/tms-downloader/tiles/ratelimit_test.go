@@ -0,0 +1,56 @@
+package tiles
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWeight(t *testing.T) {
+	limiter := NewRateLimiter(100)
+	ctx := context.Background()
+	tests := []struct {
+		weight   float64
+		min, max time.Duration
+	}{
+		// The first request is not delayed, the
+		// weight defers the following one.
+		{1, 0, 5 * time.Millisecond},
+		{4, 5 * time.Millisecond, 30 * time.Millisecond},
+		{1, 35 * time.Millisecond, 80 * time.Millisecond},
+	}
+	for i, test := range tests {
+		start := time.Now()
+		if err := limiter.Wait(ctx, test.weight); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed < test.min || elapsed > test.max {
+			t.Errorf("request %v waited %v, want %v to %v", i+1, elapsed, test.min, test.max)
+		}
+	}
+
+	var unlimited *RateLimiter
+	if err := unlimited.Wait(ctx, 10); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestZoomWeight(t *testing.T) {
+	options := Options{Zooms: Zooms{5, 3, 4}, ZoomWeight: 2, WaitTime: 100}
+	for zoom, want := range map[int]float64{3: 1, 4: 2, 5: 4} {
+		if got := options.zoomWeight(zoom); got != want {
+			t.Errorf("zoomWeight(%v) = %v, want %v", zoom, got, want)
+		}
+		if got := options.Wait(zoom); got != time.Duration(want*100)*time.Millisecond {
+			t.Errorf("Wait(%v) = %v, want %vms", zoom, got, want*100)
+		}
+	}
+	options.RPS = 10
+	if got := options.Wait(5); got != 0 {
+		t.Errorf("Wait with RPS = %v, want 0", got)
+	}
+	options.ZoomWeight = 0
+	if got := options.zoomWeight(5); got != 1 {
+		t.Errorf("zoomWeight without ZoomWeight = %v, want 1", got)
+	}
+}
@@ -0,0 +1,96 @@
+package tiles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"path"
+	"strings"
+
+	"github.com/Luqqk/wms-tiles-downloader/pkg/mercantile"
+)
+
+// ReprojectEPSGs lists the target projections supported by Reproject.
+var ReprojectEPSGs = []int{4326}
+
+// Reproject warps the web mercator image of the tile into the
+// projection given by EPSG code, covering the same geographic bounds.
+// Only EPSG:4326 (plate carrée) is supported. Pixels are resampled
+// by nearest neighbour, keeping the palette of paletted images.
+// Animated GIFs fail. This is experimental.
+func Reproject(tile *Tile, epsg int) error {
+	if epsg != 4326 {
+		return fmt.Errorf("Reprojection to EPSG:%v is not supported", epsg)
+	}
+	src, format, err := image.Decode(bytes.NewReader(tile.Content))
+	if err != nil {
+		return err
+	}
+	if format == "gif" && animated(tile.Content) {
+		return errors.New("Animated GIF tiles can not be reprojected")
+	}
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	lngLat := geographicBounds(tile.TileID)
+	mercTop := mercatorY(lngLat.Top)
+	mercBottom := mercatorY(lngLat.Bottom)
+
+	var dst draw.Image = image.NewRGBA(image.Rect(0, 0, width, height))
+	if paletted, ok := src.(*image.Paletted); ok {
+		dst = image.NewPaletted(dst.Bounds(), paletted.Palette)
+	}
+	for j := 0; j < height; j++ {
+		lat := lngLat.Top - (float64(j)+0.5)/float64(height)*(lngLat.Top-lngLat.Bottom)
+		srcY := int((mercTop - mercatorY(lat)) / (mercTop - mercBottom) * float64(height))
+		if srcY >= height {
+			srcY = height - 1
+		}
+		// Longitude is linear in both projections.
+		draw.Draw(dst, image.Rect(0, j, width, j+1), src, image.Pt(bounds.Min.X, bounds.Min.Y+srcY), draw.Src)
+	}
+
+	content, err := encodeImage(dst, format)
+	if err != nil {
+		return err
+	}
+	tile.Content = content
+	return nil
+}
+
+// SaveWorldFile saves a world file georeferencing the tile of
+// size pixels in the projection of EPSG code srs (see TileBounds)
+// next to the tile (y.pgw for y.png, y.jgw for y.jpg). The image
+// is not decoded, so any format will do.
+func SaveWorldFile(tile *Tile, srs int, size int, mode FileMode) error {
+	bounds := TileBounds(tile.TileID, srs)
+	pixelX := (bounds.Right - bounds.Left) / float64(size)
+	pixelY := (bounds.Top - bounds.Bottom) / float64(size)
+	content := fmt.Sprintf("%.12f\n0\n0\n%.12f\n%.12f\n%.12f\n",
+		pixelX, -pixelY, bounds.Left+pixelX/2, bounds.Top-pixelY/2)
+
+	ext := path.Ext(tile.Name)
+	worldExt := ".wld"
+	if len(ext) == 4 {
+		worldExt = "." + ext[1:2] + ext[3:4] + "w"
+	}
+	name := strings.TrimSuffix(tile.Name, ext) + worldExt
+	return mode.WriteFile(path.Join(tile.Path, name), []byte(content))
+}
+
+// geographicBounds returns the (lon, lat) bounding box of a tile.
+func geographicBounds(tileID mercantile.TileID) mercantile.Bbox {
+	ul := mercantile.Ul(tileID)
+	lr := mercantile.Ul(mercantile.TileID{X: tileID.X + 1, Y: tileID.Y + 1, Z: tileID.Z})
+	return mercantile.Bbox{Left: ul.Lng, Bottom: lr.Lat, Right: lr.Lng, Top: ul.Lat}
+}
+
+// mercatorY returns the unscaled Spherical Mercator y of a latitude.
+func mercatorY(lat float64) float64 {
+	return math.Log(math.Tan(math.Pi/4 + lat*math.Pi/360))
+}
@@ -0,0 +1,45 @@
+package tiles
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/Luqqk/wms-tiles-downloader/pkg/mercantile"
+)
+
+func TestSaveWorldFile(t *testing.T) {
+	tileID := mercantile.TileID{X: 1, Y: 0, Z: 1}
+	bounds := mercantile.XyBounds(tileID)
+	// 20037508.34 m across 512 pixels of a retina tile.
+	pixel := (bounds.Right - bounds.Left) / 512
+	want := fmt.Sprintf("%.12f\n0\n0\n%.12f\n%.12f\n%.12f\n", pixel, -pixel, bounds.Left+pixel/2, bounds.Top-pixel/2)
+
+	tests := []struct {
+		name      string
+		worldFile string
+	}{
+		{"0.png", "0.pgw"},
+		{"0.jpg", "0.jgw"},
+		// Neither format can be decoded here.
+		{"0.webp", "0.wld"},
+		{"0.pbf", "0.pfw"},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		tile := &Tile{Content: []byte("not an image"), Path: dir, Name: test.name, TileID: tileID}
+		if err := SaveWorldFile(tile, 3857, 512, 0); err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		content, err := os.ReadFile(path.Join(dir, test.worldFile))
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if string(content) != want {
+			t.Errorf("%v: world file is\n%s\nwant\n%s", test.name, content, want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package tiles
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/Luqqk/wms-tiles-downloader/pkg/mercantile"
+)
+
+// RequestTemplateData is passed to request templates.
+// URL is Options.URL with the tile's coordinates substituted,
+// Bbox the tile's bounds in Options.SRS as formatted by
+// FormatTileBboxSRS and Left, Bottom, Right, Top the same
+// bounds as numbers.
+type RequestTemplateData struct {
+	X, Y, Z                  int
+	URL                      string
+	Bbox                     string
+	Left, Bottom, Right, Top float64
+}
+
+// ParseRequestTemplate parses a text/template file rendering the
+// full request of a tile. The first line of the output holds the
+// method and URL, followed by "Name: value" header lines, an empty
+// line and the request body:
+//
+//	POST {{.URL}}
+//	Content-Type: application/json
+//
+//	{"z": {{.Z}}, "x": {{.X}}, "y": {{.Y}}}
+func ParseRequestTemplate(filename string) (*template.Template, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return template.New(filename).Option("missingkey=error").Parse(string(content))
+}
+
+// newTemplateRequest renders the request of the tile.
+func newTemplateRequest(ctx context.Context, t *template.Template, tileID mercantile.TileID, options Options) (*http.Request, error) {
+	bbox := TileBounds(tileID, options.SRS)
+	data := RequestTemplateData{
+		X:      tileID.X,
+		Y:      tileID.Y,
+		Z:      tileID.Z,
+		URL:    getUrlWithCoordinates(options.URL, tileID, options),
+		Bbox:   FormatTileBboxSRS(tileID, options.SRS),
+		Left:   bbox.Left,
+		Bottom: bbox.Bottom,
+		Right:  bbox.Right,
+		Top:    bbox.Top,
+	}
+	var rendered bytes.Buffer
+	if err := t.Execute(&rendered, data); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(&rendered)
+	requestLine, err := reader.ReadString('\n')
+	if err != nil && requestLine == "" {
+		return nil, fmt.Errorf("Request template %v rendered no request line", t.Name())
+	}
+	fields := strings.Fields(requestLine)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("Request template %v: expected \"METHOD URL\", got %q", t.Name(), strings.TrimSpace(requestLine))
+	}
+
+	header := http.Header{}
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Request template %v: malformed header %q", t.Name(), line)
+		}
+		header.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		if err != nil {
+			break
+		}
+	}
+
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, fields[0], fields[1], bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	return req, nil
+}
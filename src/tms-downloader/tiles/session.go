@@ -0,0 +1,93 @@
+package tiles
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"sync"
+)
+
+// HeaderNames stores names of HTTP headers.
+type HeaderNames []string
+
+// String is the method to format the flag's value, part of the flag.Value interface.
+// The String method's output will be used in diagnostics.
+func (names *HeaderNames) String() string {
+	return fmt.Sprint(*names)
+}
+
+// Set is the method to set the flag value, part of the flag.Value interface.
+// Converts comma-separated values (string in "name,name,(...)" format)
+// to HeaderNames type.
+func (names *HeaderNames) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return fmt.Errorf("Empty header name in %q", value)
+		}
+		*names = append(*names, name)
+	}
+	return nil
+}
+
+// Session holds headers captured from the first successful
+// response, which are sent with all following requests, and
+// the cookies of the requests with Options.Cookies. This serves
+// tile servers which hand out a session or CSRF token that has
+// to be echoed back. A session is shared by the requests of a
+// download (see Options.Session).
+type Session struct {
+	mu       sync.Mutex
+	captured bool
+	headers  http.Header
+	jar      http.CookieJar
+}
+
+// NewSession returns a session without headers or cookies.
+func NewSession() *Session {
+	// New returns no error without options.
+	jar, _ := cookiejar.New(nil)
+	return &Session{headers: http.Header{}, jar: jar}
+}
+
+// capture stores the named headers of the response,
+// unless they have been captured already. A nil
+// session captures nothing.
+func (session *Session) capture(resp *http.Response, names HeaderNames) {
+	if session == nil || len(names) == 0 {
+		return
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.captured {
+		return
+	}
+	for _, name := range names {
+		if value := resp.Header.Get(name); value != "" {
+			session.headers.Set(name, value)
+		}
+	}
+	session.captured = true
+}
+
+// apply sets the captured headers on the request.
+func (session *Session) apply(req *http.Request) {
+	if session == nil {
+		return
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	for name, values := range session.headers {
+		req.Header[name] = values
+	}
+}
+
+// cookieJar returns the jar of the session's cookies,
+// a new one for a nil session.
+func (session *Session) cookieJar() http.CookieJar {
+	if session == nil {
+		return NewSession().jar
+	}
+	return session.jar
+}
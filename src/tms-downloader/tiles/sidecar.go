@@ -0,0 +1,51 @@
+package tiles
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Luqqk/wms-tiles-downloader/pkg/mercantile"
+)
+
+// Sidecar is the metadata of a tile written by SaveSidecar.
+// Bounds are left, bottom, right, top in Spherical Mercator
+// meters (EPSG:3857).
+type Sidecar struct {
+	Z           int        `json:"z"`
+	X           int        `json:"x"`
+	Y           int        `json:"y"`
+	Bounds      [4]float64 `json:"bounds"`
+	ContentType string     `json:"content_type"`
+	Size        int        `json:"size"`
+	Fetched     time.Time  `json:"fetched"`
+	URL         string     `json:"url"`
+	StatusCode  int        `json:"status"`
+}
+
+// SaveSidecar saves metadata of the tile as JSON next to
+// the tile, with the tile's extension replaced by ".json".
+func SaveSidecar(tile *Tile, mode FileMode) error {
+	bounds := mercantile.XyBounds(tile.TileID)
+	sidecar := Sidecar{
+		Z:           tile.TileID.Z,
+		X:           tile.TileID.X,
+		Y:           tile.TileID.Y,
+		Bounds:      [4]float64{bounds.Left, bounds.Bottom, bounds.Right, bounds.Top},
+		ContentType: tile.ContentType,
+		Size:        len(tile.Content),
+		Fetched:     tile.Fetched,
+		URL:         tile.URL,
+		StatusCode:  tile.StatusCode,
+	}
+	content, err := json.Marshal(sidecar)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSuffix(tile.Name, path.Ext(tile.Name)) + ".json"
+	if err := mode.mkdirAll(tile.Path); err != nil {
+		return err
+	}
+	return mode.WriteFile(path.Join(tile.Path, name), content)
+}
@@ -0,0 +1,49 @@
+package tiles
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultSubdomains are substituted for {s} in the url
+// if no subdomains are configured.
+var DefaultSubdomains = Subdomains{"a", "b", "c"}
+
+// Subdomains stores subdomains of the tile server
+// requests rotate through.
+type Subdomains []string
+
+// String is the method to format the flag's value, part of the flag.Value interface.
+// The String method's output will be used in diagnostics.
+func (subdomains *Subdomains) String() string {
+	return fmt.Sprint(*subdomains)
+}
+
+// Set is the method to set the flag value, part of the flag.Value interface.
+// Converts comma-separated values (string in "a,b,(...)" format)
+// to Subdomains type.
+func (subdomains *Subdomains) Set(value string) error {
+	for _, subdomain := range strings.Split(value, ",") {
+		subdomain = strings.TrimSpace(subdomain)
+		if subdomain == "" {
+			return fmt.Errorf("Empty subdomain in %q", value)
+		}
+		*subdomains = append(*subdomains, subdomain)
+	}
+	return nil
+}
+
+// Number of urls with {s} formatted, used for round-robin rotation.
+var subdomainCounter uint64
+
+// subdomain returns the subdomain of the next request,
+// picked round-robin.
+func subdomain(options Options) string {
+	subdomains := options.Subdomains
+	if len(subdomains) == 0 {
+		subdomains = DefaultSubdomains
+	}
+	n := atomic.AddUint64(&subdomainCounter, 1) - 1
+	return subdomains[n%uint64(len(subdomains))]
+}
@@ -0,0 +1,96 @@
+package tiles
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// tileJSON holds the fields of a TileJSON document
+// (https://github.com/mapbox/tilejson-spec) used to
+// configure a download.
+type tileJSON struct {
+	Tiles   []string  `json:"tiles"`
+	MinZoom *int      `json:"minzoom"`
+	MaxZoom *int      `json:"maxzoom"`
+	Bounds  []float64 `json:"bounds"`
+	Scheme  string    `json:"scheme"`
+}
+
+// ReadTileJSON fetches the TileJSON document at TileJSON and
+// sets URL to its first tiles url, Zooms to minzoom-maxzoom,
+// Bbox to its bounds and Scheme to its scheme. Those set
+// already, e.g. by flags or the config file, are kept.
+func (options *Options) ReadTileJSON(ctx context.Context) error {
+	doc, err := fetchTileJSON(ctx, options.TileJSON, *options)
+	if err != nil {
+		return fmt.Errorf("Reading TileJSON: %v", err)
+	}
+
+	if options.URL == "" {
+		if len(doc.Tiles) == 0 {
+			return fmt.Errorf("TileJSON %v has no tiles url", options.TileJSON)
+		}
+		// Tiles urls may be relative to the document.
+		base, err := url.Parse(options.TileJSON)
+		if err != nil {
+			return err
+		}
+		ref, err := url.Parse(doc.Tiles[0])
+		if err != nil {
+			return fmt.Errorf("TileJSON %v: invalid tiles url: %v", options.TileJSON, err)
+		}
+		// Resolving escapes the braces of the placeholders.
+		options.URL = placeholderBraces.Replace(base.ResolveReference(ref).String())
+	}
+	if options.Zooms == nil && doc.MinZoom != nil && doc.MaxZoom != nil {
+		if err := options.Zooms.Set(fmt.Sprintf("%v-%v", *doc.MinZoom, *doc.MaxZoom)); err != nil {
+			return fmt.Errorf("TileJSON %v: invalid zooms: %v", options.TileJSON, err)
+		}
+	}
+	if options.Bbox == (Bbox{}) && len(doc.Bounds) == 4 {
+		options.Bbox = Bbox{Left: doc.Bounds[0], Bottom: doc.Bounds[1], Right: doc.Bounds[2], Top: doc.Bounds[3]}
+	}
+	if options.Scheme == "" && doc.Scheme != "" {
+		options.Scheme = doc.Scheme
+	}
+	return nil
+}
+
+var placeholderBraces = strings.NewReplacer("%7B", "{", "%7D", "}")
+
+// fetchTileJSON gets and parses the TileJSON document at rawURL.
+func fetchTileJSON(ctx context.Context, rawURL string, options Options) (*tileJSON, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	options.Headers.apply(req)
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", userAgent(options))
+	}
+	if options.Username != "" && options.Password != "" {
+		req.SetBasicAuth(options.Username, options.Password)
+	}
+	res, err := httpClient(options).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, &StatusError{StatusCode: res.StatusCode, URL: withoutCredentials(req.URL)}
+	}
+	content, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	var doc tileJSON
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
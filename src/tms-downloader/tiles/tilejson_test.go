@@ -0,0 +1,57 @@
+package tiles
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestReadTileJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"tilejson": "3.0.0",
+			"tiles": ["tiles/{z}/{x}/{y}.png"],
+			"minzoom": 2,
+			"maxzoom": 4,
+			"bounds": [19, 59.5, 32, 70.1],
+			"scheme": "tms"
+		}`))
+	}))
+	defer server.Close()
+
+	options := Options{TileJSON: server.URL + "/maps/tilejson.json"}
+	if err := options.ReadTileJSON(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := server.URL + "/maps/tiles/{z}/{x}/{y}.png"; options.URL != want {
+		t.Errorf("got url %v, want %v", options.URL, want)
+	}
+	if !reflect.DeepEqual(options.Zooms, Zooms{2, 3, 4}) {
+		t.Errorf("got zooms %v, want 2-4", options.Zooms)
+	}
+	if want := (Bbox{Left: 19, Bottom: 59.5, Right: 32, Top: 70.1}); options.Bbox != want {
+		t.Errorf("got bbox %v, want %v", options.Bbox, want)
+	}
+	if options.Scheme != "tms" {
+		t.Errorf("got scheme %q, want tms", options.Scheme)
+	}
+
+	// Options set already are kept.
+	given := Options{
+		TileJSON: options.TileJSON,
+		URL:      "https://tiles.example/{z}/{x}/{y}.jpg",
+		Zooms:    Zooms{7},
+		Bbox:     Bbox{Left: 24, Bottom: 60, Right: 25, Top: 61},
+		Scheme:   "xyz",
+	}
+	want := given
+	if err := given.ReadTileJSON(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(given, want) {
+		t.Errorf("got options %+v, want %+v", given, want)
+	}
+}
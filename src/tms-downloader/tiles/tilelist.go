@@ -0,0 +1,59 @@
+package tiles
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Luqqk/wms-tiles-downloader/pkg/mercantile"
+)
+
+// ReadTileList reads tiles from the file, one tile per line
+// in z/x/y format. Empty lines and lines starting with # are
+// ignored, as is anything after the tile, such as the URL in
+// a failures file.
+func ReadTileList(filename string) ([]mercantile.TileID, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var tileIDs []mercantile.TileID
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tileID, err := ParseTileID(strings.Fields(line)[0])
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v: %v", filename, n, err)
+		}
+		tileIDs = append(tileIDs, tileID)
+	}
+	return tileIDs, scanner.Err()
+}
+
+// ParseTileID parses a tile in z/x/y format.
+func ParseTileID(value string) (mercantile.TileID, error) {
+	var tileID mercantile.TileID
+	parts := strings.Split(strings.TrimSpace(value), "/")
+	if len(parts) != 3 {
+		return tileID, fmt.Errorf("Malformed tile %q, expected z/x/y", value)
+	}
+	var coordinates [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return tileID, fmt.Errorf("Malformed tile %q, expected z/x/y", value)
+		}
+		coordinates[i] = n
+	}
+	tileID = GetTileID(coordinates[1], coordinates[2], coordinates[0])
+	if max := 1 << uint(tileID.Z); tileID.X >= max || tileID.Y >= max {
+		return tileID, fmt.Errorf("Tile %q is outside of zoom %v", value, tileID.Z)
+	}
+	return tileID, nil
+}
@@ -0,0 +1,82 @@
+package tiles
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Luqqk/wms-tiles-downloader/pkg/mercantile"
+)
+
+func TestParseTileID(t *testing.T) {
+	tests := []struct {
+		value string
+		want  mercantile.TileID
+		valid bool
+	}{
+		{"3/1/2", GetTileID(1, 2, 3), true},
+		{" 0/0/0 ", GetTileID(0, 0, 0), true},
+		{"18/262143/262143", GetTileID(262143, 262143, 18), true},
+		// Malformed.
+		{"", mercantile.TileID{}, false},
+		{"3/1", mercantile.TileID{}, false},
+		{"3/1/2/4", mercantile.TileID{}, false},
+		{"3/x/2", mercantile.TileID{}, false},
+		{"3/-1/2", mercantile.TileID{}, false},
+		{"-3/1/2", mercantile.TileID{}, false},
+		{"3/1.5/2", mercantile.TileID{}, false},
+		{"3,1,2", mercantile.TileID{}, false},
+		// Outside of the zoom.
+		{"3/8/2", mercantile.TileID{}, false},
+		{"3/1/8", mercantile.TileID{}, false},
+		{"0/1/0", mercantile.TileID{}, false},
+	}
+	for _, test := range tests {
+		tileID, err := ParseTileID(test.value)
+		switch {
+		case test.valid && err != nil:
+			t.Errorf("ParseTileID(%q): %v", test.value, err)
+		case !test.valid && err == nil:
+			t.Errorf("ParseTileID(%q) = %v, want an error", test.value, tileID)
+		case test.valid && tileID != test.want:
+			t.Errorf("ParseTileID(%q) = %v, want %v", test.value, tileID, test.want)
+		}
+	}
+}
+
+func TestReadTileList(t *testing.T) {
+	dir := t.TempDir()
+	filename := path.Join(dir, "tiles.txt")
+	content := `# Failed tiles
+3/1/2 https://tile.example.com/3/1/2.png
+
+  4/5/6
+#4/0/0
+0/0/0`
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tileIDs, err := ReadTileList(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []mercantile.TileID{GetTileID(1, 2, 3), GetTileID(5, 6, 4), GetTileID(0, 0, 0)}
+	if !reflect.DeepEqual(tileIDs, want) {
+		t.Errorf("got %v, want %v", tileIDs, want)
+	}
+
+	for _, line := range []string{"3/1", "3/8/2", "z/x/y"} {
+		if err := os.WriteFile(filename, []byte("0/0/0\n"+line+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ReadTileList(filename)
+		if err == nil || !strings.Contains(err.Error(), filename+":2:") {
+			t.Errorf("line %q: got error %v, want one of line 2", line, err)
+		}
+	}
+	if _, err := ReadTileList(path.Join(dir, "missing.txt")); err == nil {
+		t.Error("missing file was read")
+	}
+}
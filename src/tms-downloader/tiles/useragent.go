@@ -0,0 +1,69 @@
+package tiles
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultUserAgent is sent when no user agents are configured.
+const DefaultUserAgent = "tms-downloader"
+
+// UserAgents stores user agent strings requests rotate through.
+type UserAgents []string
+
+// String is the method to format the flag's value, part of the flag.Value interface.
+// The String method's output will be used in diagnostics.
+func (agents *UserAgents) String() string {
+	return fmt.Sprint(*agents)
+}
+
+// Set is the method to set the flag value, part of the flag.Value interface.
+// Each use of the flag adds one user agent, as they may contain commas.
+// Empty values are ignored, so that the default is sent instead of
+// an empty header.
+func (agents *UserAgents) Set(value string) error {
+	if value = strings.TrimSpace(value); value != "" {
+		*agents = append(*agents, value)
+	}
+	return nil
+}
+
+// ReadFile adds user agents from the file, one per line.
+// Empty lines and lines starting with # are ignored.
+func (agents *UserAgents) ReadFile(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		*agents = append(*agents, line)
+	}
+	return scanner.Err()
+}
+
+// Number of requests made, used for round-robin rotation.
+var userAgentCounter uint64
+
+// userAgent returns the user agent for the next request,
+// picked round-robin or randomly if options ask so.
+func userAgent(options Options) string {
+	agents := options.UserAgents
+	if len(agents) == 0 {
+		return DefaultUserAgent
+	}
+	if options.RandomUserAgent {
+		return agents[rand.Intn(len(agents))]
+	}
+	n := atomic.AddUint64(&userAgentCounter, 1) - 1
+	return agents[n%uint64(len(agents))]
+}
@@ -0,0 +1,624 @@
+package tiles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/color"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Luqqk/wms-tiles-downloader/pkg/mercantile"
+)
+
+// testTile is requested by the tests of Get.
+var testTile = mercantile.TileID{X: 1, Y: 2, Z: 3}
+
+// truncate writes the first half of content, announcing all of
+// it with Content-Length, and closes the connection.
+func truncate(t *testing.T, w http.ResponseWriter, content []byte) {
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(content[:len(content)/2])
+	w.(http.Flusher).Flush()
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	conn.Close()
+}
+
+func TestGetTruncatedBody(t *testing.T) {
+	content := pngTile(t, color.White)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"closed mid-body", func(w http.ResponseWriter, r *http.Request) {
+			truncate(t, w, content)
+		}},
+		{"short Content-Length", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)+100))
+			w.Write(content)
+		}},
+	}
+	for _, test := range tests {
+		server := httptest.NewServer(test.handler)
+		options := Options{URL: server.URL + "/{z}/{x}/{y}.png", OutputDir: t.TempDir()}
+		tile, err := Get(context.Background(), testTile, options)
+		server.Close()
+		if tile != nil {
+			t.Errorf("%v: got a tile of %v bytes, want none", test.name, len(tile.Content))
+		}
+		if !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%v: got error %v, want ErrCorrupt", test.name, err)
+		}
+		var fetchErr *FetchError
+		if !errors.As(err, &fetchErr) || fetchErr.StatusCode != http.StatusOK {
+			t.Errorf("%v: got error %#v, want a *FetchError with status 200", test.name, err)
+		}
+	}
+}
+
+func TestGetRetriesTruncatedBody(t *testing.T) {
+	content := pngTile(t, color.White)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			truncate(t, w, content)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	options := Options{URL: server.URL + "/{z}/{x}/{y}.png", OutputDir: t.TempDir(), Retries: 1}
+	tile, err := Get(context.Background(), testTile, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tile.Content, content) {
+		t.Errorf("got %v bytes, want %v", len(tile.Content), len(content))
+	}
+	if requests != 2 {
+		t.Errorf("got %v requests, want 2", requests)
+	}
+}
+
+func TestDownloadRetriesServerErrors(t *testing.T) {
+	content := pngTile(t, color.White)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&requests, 1) {
+		case 1:
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		case 2:
+			// A dropped connection.
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			conn.Close()
+		default:
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(content)
+		}
+	}))
+	defer server.Close()
+
+	options := Options{
+		URL:          server.URL + "/{z}/{x}/{y}.png",
+		OutputDir:    t.TempDir(),
+		Retries:      3,
+		RetryBackoff: time.Millisecond,
+	}
+	jobs, err := DownloadTiles(context.Background(), []mercantile.TileID{testTile}, options, Hooks{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs.Succeeded != 1 || jobs.Failed != 0 {
+		t.Errorf("got %v succeeded and %v failed tiles, want 1 and 0", jobs.Succeeded, jobs.Failed)
+	}
+	if requests != 3 {
+		t.Errorf("got %v requests, want 3", requests)
+	}
+}
+
+func TestDownloadDoesNotRetryNotFound(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	options := Options{
+		URL:          server.URL + "/{z}/{x}/{y}.png",
+		OutputDir:    t.TempDir(),
+		Retries:      3,
+		RetryBackoff: time.Millisecond,
+	}
+	var results []Result
+	jobs, err := DownloadTiles(context.Background(), []mercantile.TileID{testTile}, options, Hooks{
+		Done: func(result Result) error {
+			results = append(results, result)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs.Succeeded != 0 || jobs.Failed != 1 {
+		t.Errorf("got %v succeeded and %v failed tiles, want 0 and 1", jobs.Succeeded, jobs.Failed)
+	}
+	if len(results) != 1 || !errors.Is(results[0].Err, ErrNotFound) {
+		t.Errorf("got results %+v, want one of ErrNotFound", results)
+	}
+	if requests != 1 {
+		t.Errorf("got %v requests, want 1", requests)
+	}
+}
+
+func TestFlipY(t *testing.T) {
+	tests := []struct {
+		z, y, want int
+	}{
+		{0, 0, 0},
+		{1, 0, 1},
+		{1, 1, 0},
+		{2, 0, 3},
+		{2, 1, 2},
+		{3, 2, 5},
+		{10, 0, 1023},
+		{10, 300, 723},
+		{18, 100000, 162143},
+	}
+	for _, test := range tests {
+		if got := FlipY(test.y, test.z); got != test.want {
+			t.Errorf("FlipY(%v, %v) = %v, want %v", test.y, test.z, got, test.want)
+		}
+	}
+	for z := 0; z <= 8; z++ {
+		for y := 0; y < 1<<uint(z); y++ {
+			if got := FlipY(FlipY(y, z), z); got != y {
+				t.Errorf("FlipY(FlipY(%v, %v), %v) = %v", y, z, z, got)
+			}
+		}
+	}
+}
+
+// fakeProxy starts a proxy answering requests for any host
+// with content, and counting them.
+func fakeProxy(t *testing.T, content []byte) (*httptest.Server, *int32) {
+	t.Helper()
+	var requests int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Requests to proxies carry the absolute url.
+		if r.URL.Host == "" {
+			t.Errorf("proxy got a request for %v without host", r.URL)
+		}
+		atomic.AddInt32(&requests, 1)
+		w.Write(content)
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy, &requests
+}
+
+func TestGetThroughProxy(t *testing.T) {
+	content := pngTile(t, color.White)
+	proxy, requests := fakeProxy(t, content)
+	environmentProxy, environmentRequests := fakeProxy(t, nil)
+	// The flag wins over the environment.
+	t.Setenv("HTTP_PROXY", environmentProxy.URL)
+
+	transport, err := NewProxyTransport(proxy.URL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := Options{URL: "http://tiles.example/{z}/{x}/{y}.png", OutputDir: t.TempDir(), Transport: transport}
+	tile, err := Get(context.Background(), testTile, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tile.Content, content) {
+		t.Errorf("got %v bytes, want %v", len(tile.Content), len(content))
+	}
+	if *requests != 1 || *environmentRequests != 0 {
+		t.Errorf("got %v requests to the proxy and %v to the one of the environment, want 1 and 0", *requests, *environmentRequests)
+	}
+}
+
+func TestNewProxyTransportInvalidURL(t *testing.T) {
+	for _, proxyURL := range []string{"proxy:8080", "127.0.0.1:8080", "http://", "://x"} {
+		if _, err := NewProxyTransport(proxyURL, 0); err == nil {
+			t.Errorf("NewProxyTransport(%q) succeeded, want an error", proxyURL)
+		}
+	}
+}
+
+func TestDefaultClientProxyFromEnvironment(t *testing.T) {
+	// ProxyFromEnvironment reads the environment once per
+	// process, so the function is compared instead.
+	for name, c := range map[string]*http.Client{"default": client, "NewTransport": {Transport: NewTransport(4)}} {
+		transport, ok := c.Transport.(*http.Transport)
+		if !ok || reflect.ValueOf(transport.Proxy).Pointer() != reflect.ValueOf(http.ProxyFromEnvironment).Pointer() {
+			t.Errorf("%v transport does not take the proxy from the environment", name)
+		}
+	}
+}
+
+func TestGetUrlWithCoordinatesScheme(t *testing.T) {
+	tests := []struct {
+		tileID   mercantile.TileID
+		xyz, tms string
+	}{
+		{mercantile.TileID{X: 0, Y: 0, Z: 0}, "/0/0/0.png", "/0/0/0.png"},
+		{mercantile.TileID{X: 1, Y: 0, Z: 1}, "/1/1/0.png", "/1/1/1.png"},
+		{mercantile.TileID{X: 3, Y: 1, Z: 2}, "/2/3/1.png", "/2/3/2.png"},
+		{mercantile.TileID{X: 1, Y: 2, Z: 3}, "/3/1/2.png", "/3/1/5.png"},
+		{mercantile.TileID{X: 602, Y: 300, Z: 10}, "/10/602/300.png", "/10/602/723.png"},
+	}
+	for _, test := range tests {
+		for scheme, want := range map[string]string{"": test.xyz, "xyz": test.xyz, "tms": test.tms} {
+			options := Options{Scheme: scheme}
+			if got := getUrlWithCoordinates("/{z}/{x}/{y}.png", test.tileID, options); got != want {
+				t.Errorf("tile %v with scheme %q: got %v, want %v", test.tileID, scheme, got, want)
+			}
+		}
+	}
+}
+
+func TestGetUrlWithCoordinatesSize(t *testing.T) {
+	tests := []struct {
+		options Options
+		want    string
+	}{
+		{Options{TileSize: 256}, "/wms?WIDTH=256&HEIGHT=256"},
+		{Options{TileSize: 512}, "/wms?WIDTH=512&HEIGHT=512"},
+		{Options{TileSize: 256, Retina: true}, "/wms?WIDTH=512&HEIGHT=512"},
+		{Options{}, "/wms?WIDTH=256&HEIGHT=256"},
+	}
+	for _, test := range tests {
+		if got := getUrlWithCoordinates("/wms?WIDTH={width}&HEIGHT={height}", testTile, test.options); got != test.want {
+			t.Errorf("tile size %v, retina %v: got %v, want %v", test.options.TileSize, test.options.Retina, got, test.want)
+		}
+	}
+}
+
+func TestGetErrorStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusForbidden, &StatusError{}},
+		{http.StatusInternalServerError, &StatusError{}},
+	}
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			w.WriteHeader(test.status)
+			w.Write([]byte("error page"))
+		}))
+		options := Options{URL: server.URL + "/{z}/{x}/{y}.png", OutputDir: t.TempDir()}
+
+		tile, err := Get(context.Background(), testTile, options)
+		var statusErr *StatusError
+		switch {
+		case tile != nil:
+			t.Errorf("status %v: got a tile, want none", test.status)
+		case test.want == ErrNotFound && !errors.Is(err, ErrNotFound):
+			t.Errorf("status %v: got error %v, want ErrNotFound", test.status, err)
+		case test.want != ErrNotFound && (!errors.As(err, &statusErr) || statusErr.StatusCode != test.status):
+			t.Errorf("status %v: got error %v, want a *StatusError", test.status, err)
+		}
+
+		jobs, err := Download(context.Background(), Options{
+			URL:       options.URL,
+			Zooms:     Zooms{testTile.Z},
+			Bbox:      Bbox(geographicBounds(testTile)),
+			OutputDir: options.OutputDir,
+		})
+		server.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if jobs.Failed != jobs.All || jobs.All == 0 {
+			t.Errorf("status %v: got %v of %v tiles failed, want all", test.status, jobs.Failed, jobs.All)
+		}
+		if entries, _ := os.ReadDir(options.OutputDir); len(entries) > 0 {
+			t.Errorf("status %v: files were written into the output directory", test.status)
+		}
+	}
+}
+
+func TestZoomsSet(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Zooms
+		valid bool
+	}{
+		{"3", Zooms{3}, true},
+		{"12-15", Zooms{12, 13, 14, 15}, true},
+		{"0,2,5-6", Zooms{0, 2, 5, 6}, true},
+		{"7-7", Zooms{7}, true},
+		// Duplicates are left out, in the order given.
+		{"5,3,5", Zooms{5, 3}, true},
+		{"3-5,4-6", Zooms{3, 4, 5, 6}, true},
+		// Inverted ranges.
+		{"15-12", nil, false},
+		// Malformed parts.
+		{"", nil, false},
+		{"3,", nil, false},
+		{"a", nil, false},
+		{"3-", nil, false},
+		{"-3", nil, false},
+		{"3-5-7", nil, false},
+		{"3..5", nil, false},
+		{"3.5", nil, false},
+	}
+	for _, test := range tests {
+		var zooms Zooms
+		err := zooms.Set(test.value)
+		switch {
+		case test.valid && err != nil:
+			t.Errorf("Set(%q): %v", test.value, err)
+		case !test.valid && err == nil:
+			t.Errorf("Set(%q) = %v, want an error", test.value, zooms)
+		case test.valid && !reflect.DeepEqual(zooms, test.want):
+			t.Errorf("Set(%q) = %v, want %v", test.value, zooms, test.want)
+		}
+	}
+
+	// Repeated flags add to the zooms.
+	var zooms Zooms
+	for _, value := range []string{"1-2", "2-3"} {
+		if err := zooms.Set(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(zooms, Zooms{1, 2, 3}) {
+		t.Errorf("got %v, want [1 2 3]", zooms)
+	}
+}
+
+func TestBboxSet(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Bbox
+		valid bool
+	}{
+		{"24,60,25,61", Bbox{Left: 24, Bottom: 60, Right: 25, Top: 61}, true},
+		{" 24.5, 60.1 ,25,61 ", Bbox{Left: 24.5, Bottom: 60.1, Right: 25, Top: 61}, true},
+		{"-180,-85.06,180,85.06", Bbox{Left: -180, Bottom: -85.06, Right: 180, Top: 85.06}, true},
+		// Wrong count.
+		{"", Bbox{}, false},
+		{"24,60,25", Bbox{}, false},
+		{"24,60,25,61,62", Bbox{}, false},
+		{"24 60 25 61", Bbox{}, false},
+		// Non-numeric.
+		{"24,60,east,61", Bbox{}, false},
+		{"24,60,,61", Bbox{}, false},
+		{"24,60,25,NaN", Bbox{}, false},
+		{"24,60,Inf,61", Bbox{}, false},
+		{"24°,60,25,61", Bbox{}, false},
+	}
+	for _, test := range tests {
+		var bbox Bbox
+		err := bbox.Set(test.value)
+		switch {
+		case test.valid && err != nil:
+			t.Errorf("Set(%q): %v", test.value, err)
+		case !test.valid && err == nil:
+			t.Errorf("Set(%q) = %v, want an error", test.value, bbox)
+		case !test.valid && bbox != (Bbox{}):
+			t.Errorf("Set(%q) changed the bbox to %v", test.value, bbox)
+		case test.valid && bbox != test.want:
+			t.Errorf("Set(%q) = %v, want %v", test.value, bbox, test.want)
+		}
+	}
+}
+
+func TestBboxCheck(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"24,60,25,61", true},
+		{"-180,-90,180,90", true},
+		// Latitudes out of range.
+		{"24,-91,25,61", false},
+		{"24,60,25,90.5", false},
+		{"24,95,25,100", false},
+		// Longitudes out of range.
+		{"-181,60,25,61", false},
+		{"24,60,180.1,61", false},
+		// Empty or swapped.
+		{"24,60,24,61", false},
+		{"25,60,24,61", false},
+		{"179,60,-179,61", false},
+		{"24,61,25,61", false},
+		{"24,61,25,60", false},
+	}
+	for _, test := range tests {
+		var bbox Bbox
+		if err := bbox.Set(test.value); err != nil {
+			t.Fatal(err)
+		}
+		if err := bbox.check(); (err == nil) != test.valid {
+			t.Errorf("check of %v: got error %v, want valid %v", test.value, err, test.valid)
+		}
+	}
+}
+
+func TestGetRetriesTooManyRequests(t *testing.T) {
+	content := pngTile(t, color.White)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	// The backoff would outlast the test, Retry-After is
+	// waited instead and cut to MaxRetryAfter.
+	options := Options{
+		URL:           server.URL + "/{z}/{x}/{y}.png",
+		OutputDir:     t.TempDir(),
+		Retries:       1,
+		RetryBackoff:  time.Hour,
+		MaxRetryAfter: 50 * time.Millisecond,
+	}
+	start := time.Now()
+	tile, err := Get(context.Background(), testTile, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < options.MaxRetryAfter {
+		t.Errorf("retried after %v, want at least %v", elapsed, options.MaxRetryAfter)
+	}
+	if !bytes.Equal(tile.Content, content) {
+		t.Errorf("got %v bytes, want %v", len(tile.Content), len(content))
+	}
+	if requests != 2 {
+		t.Errorf("got %v requests, want 2", requests)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"120", 2 * time.Minute, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0, true},
+	}
+	for _, test := range tests {
+		if got, ok := retryAfter(test.value); got != test.want || ok != test.ok {
+			t.Errorf("retryAfter(%q) = %v, %v, want %v, %v", test.value, got, ok, test.want, test.ok)
+		}
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got, ok := retryAfter(date); !ok || got <= 59*time.Minute || got > time.Hour {
+		t.Errorf("retryAfter(%q) = %v, %v, want about an hour", date, got, ok)
+	}
+}
+
+func TestQuadkey(t *testing.T) {
+	tests := []struct {
+		tileID mercantile.TileID
+		want   string
+	}{
+		{mercantile.TileID{X: 0, Y: 0, Z: 0}, ""},
+		{mercantile.TileID{X: 0, Y: 0, Z: 1}, "0"},
+		{mercantile.TileID{X: 1, Y: 0, Z: 1}, "1"},
+		{mercantile.TileID{X: 0, Y: 1, Z: 1}, "2"},
+		{mercantile.TileID{X: 1, Y: 1, Z: 1}, "3"},
+		// The example of the Bing Maps tile system.
+		{mercantile.TileID{X: 3, Y: 5, Z: 3}, "213"},
+		{mercantile.TileID{X: 7, Y: 7, Z: 3}, "333"},
+		{mercantile.TileID{X: 0, Y: 0, Z: 3}, "000"},
+		{mercantile.TileID{X: 602, Y: 300, Z: 10}, "1201213210"},
+	}
+	for _, test := range tests {
+		if got := Quadkey(test.tileID); got != test.want {
+			t.Errorf("Quadkey(%v) = %q, want %q", test.tileID, got, test.want)
+		}
+	}
+	got := getUrlWithCoordinates("/tiles/{q}.jpeg", mercantile.TileID{X: 3, Y: 5, Z: 3}, Options{})
+	if want := "/tiles/213.jpeg"; got != want {
+		t.Errorf("got url %v, want %v", got, want)
+	}
+}
+
+func TestTileBounds(t *testing.T) {
+	// The reference values are those of the mercantile
+	// Python package, whose ports the tiles use.
+	tests := []struct {
+		tileID mercantile.TileID
+		srs    int
+		want   mercantile.Bbox
+	}{
+		{mercantile.TileID{X: 0, Y: 0, Z: 0}, 3857, mercantile.Bbox{Left: -20037508.342789244, Bottom: -20037508.342789244, Right: 20037508.342789244, Top: 20037508.342789244}},
+		{mercantile.TileID{X: 1, Y: 0, Z: 1}, 3857, mercantile.Bbox{Left: 0, Bottom: 0, Right: 20037508.342789244, Top: 20037508.342789244}},
+		{mercantile.TileID{X: 486, Y: 332, Z: 10}, 3857, mercantile.Bbox{Left: -1017529.7205322663, Bottom: 7005300.768279833, Right: -978393.962050256, Top: 7044436.526761846}},
+		{mercantile.TileID{X: 486, Y: 332, Z: 10}, 4326, mercantile.Bbox{Left: -9.140625, Bottom: 53.12040528310657, Right: -8.7890625, Top: 53.33087298301705}},
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+	for _, test := range tests {
+		got := TileBounds(test.tileID, test.srs)
+		if !near(got.Left, test.want.Left) || !near(got.Bottom, test.want.Bottom) || !near(got.Right, test.want.Right) || !near(got.Top, test.want.Top) {
+			t.Errorf("TileBounds(%v, %v) = %+v, want %+v", test.tileID, test.srs, got, test.want)
+		}
+	}
+
+	formatted := map[int]string{
+		3857: "0.000000000,0.000000000,20037508.342789244,20037508.342789244",
+		4326: "0.000000000,0.000000000,180.000000000,85.051128780",
+	}
+	for srs, want := range formatted {
+		if got := FormatTileBboxSRS(mercantile.TileID{X: 1, Y: 0, Z: 1}, srs); got != want {
+			t.Errorf("FormatTileBboxSRS in EPSG:%v = %v, want %v", srs, got, want)
+		}
+	}
+	if got := FormatTileBbox(mercantile.TileID{X: 1, Y: 0, Z: 1}); got != formatted[3857] {
+		t.Errorf("FormatTileBbox = %v, want %v", got, formatted[3857])
+	}
+}
+
+func TestGetVerboseErrors(t *testing.T) {
+	long := strings.Repeat("error ", 20000)
+	tests := []struct {
+		body    string
+		verbose bool
+		want    string
+	}{
+		{"<ServiceException>\n  Unknown layer\n</ServiceException>\n", false, ""},
+		{"<ServiceException>\n  Unknown layer\n</ServiceException>\n", true, "<ServiceException> Unknown layer </ServiceException>"},
+		// Bounded, whatever the size of the response.
+		{long, true, long[:maxErrorBody] + "..."},
+	}
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(test.body))
+		}))
+		options := Options{URL: server.URL + "/{z}/{x}/{y}.png", OutputDir: t.TempDir(), VerboseErrors: test.verbose}
+		_, err := Get(context.Background(), testTile, options)
+		server.Close()
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) {
+			t.Errorf("got error %v, want a *StatusError", err)
+			continue
+		}
+		if statusErr.Body != test.want {
+			t.Errorf("VerboseErrors %v: got a body of %v bytes, want %v bytes %.60q", test.verbose, len(statusErr.Body), len(test.want), test.want)
+		}
+		if test.verbose && !strings.HasSuffix(err.Error(), ": "+test.want) {
+			t.Errorf("error %.120q does not end in the body", err.Error())
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package tiles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	// Register decoders of raster formats verified.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// ErrCorrupt is wrapped by errors of tiles whose body was cut
+// short or fails to decompress, and of verifying tiles which
+// are not decodable images of the expected size, for example
+// truncated ones or HTML error pages served as images.
+var ErrCorrupt = errors.New("Corrupt tile image")
+
+// verifyImage checks that the header of content is a PNG, JPEG
+// or GIF image of size x size pixels.
+func verifyImage(content []byte, size int) error {
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	if config.Width != size || config.Height != size {
+		return fmt.Errorf("%w: %vx%v pixels, expected %vx%v", ErrCorrupt, config.Width, config.Height, size, size)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package tiles
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// WMTSURL returns options.URL, the endpoint of an OGC WMTS
+// service, with the KVP parameters of a GetTile request for
+// options.WMTSLayer, WMTSStyle and WMTSMatrixSet. The tile
+// matrix, row and column are the {z}, {y} and {x} placeholders.
+// FORMAT is the media type of options.Format (see
+// wmtsMediaType), image/png if not set.
+func WMTSURL(options Options) string {
+	format := "image/png"
+	if options.Format != "" {
+		format = wmtsMediaType(options.Format)
+	}
+	style := options.WMTSStyle
+	if style == "" {
+		style = "default"
+	}
+	separator := "?"
+	if strings.Contains(options.URL, "?") {
+		separator = "&"
+		if strings.HasSuffix(options.URL, "?") || strings.HasSuffix(options.URL, "&") {
+			separator = ""
+		}
+	}
+	return fmt.Sprintf("%v%vSERVICE=WMTS&REQUEST=GetTile&VERSION=1.0.0&LAYER=%v&STYLE=%v&FORMAT=%v&TILEMATRIXSET=%v&TILEMATRIX={z}&TILEROW={y}&TILECOL={x}",
+		options.URL, separator,
+		url.QueryEscape(options.WMTSLayer),
+		url.QueryEscape(style),
+		url.QueryEscape(format),
+		url.QueryEscape(options.WMTSMatrixSet))
+}
+
+// wmtsFormats are the media types of the formats a WMTS
+// tile can be requested in, by extension.
+var wmtsFormats = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".webp": "image/webp",
+	".gif":  "image/gif",
+	".tif":  "image/tiff",
+	".tiff": "image/tiff",
+	".pbf":  "application/vnd.mapbox-vector-tile",
+	".mvt":  "application/vnd.mapbox-vector-tile",
+}
+
+// wmtsMediaType returns the media type of tiles in format,
+// an extension like "jpg", or "" if it is not known.
+func wmtsMediaType(format string) string {
+	return wmtsFormats[strings.ToLower(Extension(format, ""))]
+}
@@ -0,0 +1,37 @@
+package tiles
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestWMTSURLFormat(t *testing.T) {
+	tests := map[string]string{
+		"":     "image/png",
+		"png":  "image/png",
+		"jpg":  "image/jpeg",
+		"jpeg": "image/jpeg",
+		".JPG": "image/jpeg",
+		"webp": "image/webp",
+		"pbf":  "application/vnd.mapbox-vector-tile",
+	}
+	for format, want := range tests {
+		options := Options{URL: "https://wmts.example/service", WMTSLayer: "ortho", WMTSMatrixSet: "WebMercatorQuad", Format: format}
+		u, err := url.Parse(WMTSURL(options))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := u.Query().Get("FORMAT"); got != want {
+			t.Errorf("format %q: got FORMAT %v, want %v", format, got, want)
+		}
+	}
+}
+
+func TestWMTSMediaTypeUnknown(t *testing.T) {
+	// ValidateOptions rejects these.
+	for _, format := range []string{"bmp", "image/jpeg", "svg"} {
+		if got := wmtsMediaType(format); got != "" {
+			t.Errorf("wmtsMediaType(%q) = %v, want none", format, got)
+		}
+	}
+}
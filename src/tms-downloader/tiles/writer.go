@@ -0,0 +1,48 @@
+package tiles
+
+import "github.com/Luqqk/wms-tiles-downloader/pkg/mercantile"
+
+// TileWriter stores downloaded tiles, in the z/x/y tree
+// (FileWriter) or in a container like MBTiles.
+type TileWriter interface {
+	Write(tile *Tile) error
+}
+
+// TileStore is a TileWriter of a container which tells the
+// tiles it holds already, to skip them with Options.Resume.
+type TileStore interface {
+	TileWriter
+	Has(tileID mercantile.TileID) bool
+}
+
+// FileWriter saves tiles in the z/x/y tree (see Save), with
+// world files, checksums and sidecar JSON files if Options
+// ask so. Reprojected tiles always get world files.
+type FileWriter struct {
+	Options Options
+}
+
+// Write saves the tile and its accompanying files.
+func (writer FileWriter) Write(tile *Tile) error {
+	if err := Save(tile, writer.Options); err != nil {
+		return err
+	}
+	if reproject := writer.Options.Reproject; reproject != 0 && reproject != 3857 {
+		if err := SaveWorldFile(tile, reproject, writer.Options.imageSize(), writer.Options.FileMode); err != nil {
+			return err
+		}
+	} else if writer.Options.WorldFiles {
+		if err := SaveWorldFile(tile, 3857, writer.Options.imageSize(), writer.Options.FileMode); err != nil {
+			return err
+		}
+	}
+	if writer.Options.Checksums != "" {
+		if err := SaveChecksum(tile, writer.Options.Checksums, writer.Options.FileMode); err != nil {
+			return err
+		}
+	}
+	if writer.Options.SidecarJSON {
+		return SaveSidecar(tile, writer.Options.FileMode)
+	}
+	return nil
+}
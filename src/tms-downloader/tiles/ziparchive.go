@@ -0,0 +1,74 @@
+package tiles
+
+import (
+	"archive/zip"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ZipArchive is a ZIP file the tiles are written into instead
+// of the z/x/y tree, with entries named like the saved files
+// (see Options.NameTemplate). It is safe for concurrent use.
+type ZipArchive struct {
+	mu      sync.Mutex
+	file    *os.File
+	writer  *zip.Writer
+	options Options
+	// names of the written entries, as a
+	// tile retried must not be added twice.
+	names map[string]bool
+}
+
+// CreateZipArchive creates the archive in the file with
+// options.FileMode, replacing an existing one.
+func CreateZipArchive(filename string, options Options) (*ZipArchive, error) {
+	file, err := options.FileMode.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &ZipArchive{file: file, writer: zip.NewWriter(file), options: options, names: make(map[string]bool)}, nil
+}
+
+// Write adds the tile to the archive, unless
+// it has been added already (ErrExists).
+func (archive *ZipArchive) Write(tile *Tile) error {
+	name, err := filepath.Rel(archive.options.OutputDir, path.Join(tile.Path, tile.Name))
+	if err != nil {
+		return err
+	}
+	name = filepath.ToSlash(name)
+
+	archive.mu.Lock()
+	defer archive.mu.Unlock()
+	if archive.names[name] {
+		return ErrExists
+	}
+	fetched := tile.Fetched
+	if fetched.IsZero() {
+		fetched = time.Now()
+	}
+	entry, err := archive.writer.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: fetched})
+	if err != nil {
+		return storageError(err)
+	}
+	if _, err := entry.Write(tile.Content); err != nil {
+		return storageError(err)
+	}
+	archive.names[name] = true
+	return nil
+}
+
+// Close writes the central directory of the
+// archive and closes the file.
+func (archive *ZipArchive) Close() error {
+	archive.mu.Lock()
+	defer archive.mu.Unlock()
+	if err := archive.writer.Close(); err != nil {
+		archive.file.Close()
+		return storageError(err)
+	}
+	return storageError(archive.file.Close())
+}
@@ -0,0 +1,85 @@
+package tiles
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"image/color"
+	"io"
+	"path"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestDownloadIntoZipArchive(t *testing.T) {
+	content := pngTile(t, color.White)
+	server := serveTile(t, content)
+	dir := t.TempDir()
+	options := Options{
+		URL:       server.URL + "/{z}/{x}/{y}.png",
+		Zooms:     Zooms{0, 1},
+		Bbox:      Bbox{Left: -180, Bottom: -85, Right: 180, Top: 85},
+		OutputDir: dir,
+		Zip:       path.Join(dir, "tiles.zip"),
+		FileMode:  0600,
+	}
+	jobs, err := Download(context.Background(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs.Succeeded != 5 {
+		t.Errorf("got %v succeeded tiles, want 5", jobs.Succeeded)
+	}
+	checkMode(t, options.Zip, 0600)
+
+	archive, err := zip.OpenReader(options.Zip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	var names []string
+	for _, entry := range archive.File {
+		names = append(names, entry.Name)
+		file, err := entry.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(file)
+		file.Close()
+		if err != nil || !bytes.Equal(got, content) {
+			t.Errorf("entry %v has %v bytes and error %v, want %v bytes", entry.Name, len(got), err, len(content))
+		}
+	}
+	sort.Strings(names)
+	want := []string{"0/0/0.png", "1/0/0.png", "1/0/1.png", "1/1/0.png", "1/1/1.png"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got entries %v, want %v", names, want)
+	}
+}
+
+func TestZipArchiveRefusesDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	archive, err := CreateZipArchive(path.Join(dir, "tiles.zip"), Options{OutputDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tile := &Tile{Path: path.Join(dir, "3", "1"), Name: "2.png", Content: []byte("tile")}
+	if err := archive.Write(tile); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Write(tile); err != ErrExists {
+		t.Errorf("got error %v adding the tile again, want ErrExists", err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := zip.OpenReader(path.Join(dir, "tiles.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if len(reader.File) != 1 || reader.File[0].Name != "3/1/2.png" {
+		t.Errorf("got %v entries, want 3/1/2.png only", len(reader.File))
+	}
+}
@@ -1,80 +1,959 @@
 package main
 
 import (
-  "flag"
-  "fmt"
-  "log"
-  "os"
-  "time"
-
-  "tms-downloader/mercantile"
-  "tms-downloader/tiles"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math/rand"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	wmsmercantile "github.com/Luqqk/wms-tiles-downloader/pkg/mercantile"
+
+	"tms-downloader/mercantile"
+	"tms-downloader/tiles"
 )
 
 var usageText = `Usage:
     tms-downloader [OPTIONS]
     Download tiles from specific source and save them on hard drive.
 Options:
-    --url         TMS server url.                              REQUIRED
-    --zooms       Comma-separated list of zooms to download.   REQUIRED
-    --bbox        Comma-separated list of bbox coordinates.    REQUIRED
-    --wait        Wait time (ms) between tile downloads.       DEFAULT:1000
+    --url               TMS server url with placeholders {z}, {x}, {y},
+                        {s} (subdomain) and {q} (quadkey), or WMS
+                        GetMap url with {bbox} (see --srs), {width}
+                        and {height}.                                  REQUIRED
+    --zooms             Comma-separated list of zooms to download,
+                        ranges like 3-10 included.                     REQUIRED
+    --bbox              Comma-separated list of bbox coordinates.      REQUIRED
+                        left,bottom,right,top in degrees, not crossing
+                        the antimeridian.
+    --bbox-file         Download tiles intersecting polygons of given
+                        GeoJSON file instead of a bbox.
+    --wait              Wait time (ms) between tile downloads.         DEFAULT:1000
+    --wait-jitter       Randomize wait time by +/- given ms.           DEFAULT:0
+    --jitter            Same as --wait-jitter.
+    --rps               Max requests per second of all downloads and
+                        retries, replaces --wait if set.
+    --dump-geojson      Write tile footprints as GeoJSON to given
+                        file and exit.
+    --check-seams       Compare edges of sample adjacent tiles and exit.
+    --http-cache        Cache responses in given directory
+                        (github.com/gregjones/httpcache disk layout).
+    --strip-query       Comma-separated query parameters ignored by
+                        the cache, "*" ignores the whole query.
+    --on-tile           Shell command run for each saved tile
+                        (path in $1, TILE_Z/TILE_X/TILE_Y in env).
+                        Tiles must be saved in the z/x/y tree.
+    --on-tile-jobs      Max number of tile commands run at once.       DEFAULT:4
+    --on-tile-fatal     Abort if a tile command fails.
+    --report-missing    Write tiles not found (404) to given file.
+    --sidecar-json      Save tile's metadata as y.json next to tile.
+    --reproject         EPSG code to reproject tiles to, with world
+                        files. Experimental, only 4326 is supported.
+    --retry-passes      Passes over failed tiles after the run.        DEFAULT:0
+    --retry-pass-delay  Delay (s) before first retry pass, grows
+                        with each pass.                                DEFAULT:10
+    --sample            Download only given number of randomly picked
+                        tiles, e.g. to test the url.
+    --datauri-json      Write tiles as data URIs keyed by "z/x/y" into
+                        given JSON file instead of the z/x/y tree.
+    --session-header    Comma-separated headers captured from the first
+                        successful response and sent with all
+                        following requests.
+    --cookies           Send cookies set by the server back.
+    --output-layout     Y axis of saved tree: xyz or tms (flipped y).  DEFAULT:xyz
+    --progress-file     Write progress as JSON to given file or named
+                        pipe once a second.
+    --user-agent        User agent to send. Repeat to rotate between
+                        several, round-robin.            DEFAULT:tms-downloader
+    --user-agent-file   Read user agents from given file, one per line.
+    --user-agent-random Pick user agents randomly, not round-robin.
+                        Respect terms of the tile provider, they may
+                        forbid disguising the client.
+    --benchmark         Download a sample of tiles with concurrency
+                        1, 2, 4, 8 and 16, print throughput and exit.
+    --benchmark-sample  Number of tiles in the benchmark sample.       DEFAULT:32
+    --index-db          Record saved tiles with sizes and hashes in
+                        given SQLite database.
+    --retry-dns         Retries of a tile when resolving the server's
+                        host name fails.                               DEFAULT:2
+    --limit-per-zoom    Download at most given number of tiles of each
+                        zoom.
+    --content-addressed Save tiles once per content hash in given
+                        directory, with a z/x/y to hash index.
+    --request-template  Go text/template file rendering the full
+                        request (method, url, headers, body) of each
+                        tile.
+    --min-avg-size      Abort if average tile size (bytes) is smaller.
+    --max-avg-size      Abort if average tile size (bytes) is larger.
+    --avg-size-after    Number of saved tiles after which the average
+                        tile size is checked.                          DEFAULT:200
+    --cors-check        Send a CORS preflight request for the first
+                        tile, print Access-Control-* headers and exit.
+    --cors-origin       Origin of the CORS check.      DEFAULT:http://localhost
+    --max-age           Skip tiles saved less than given duration ago,
+                        e.g. 72h. Older tiles are downloaded again.
+    --split-output      Comma-separated root directories to spread
+                        tiles across by a hash of z/x/y.
+    --notify-url        Post final state of the run as JSON to given
+                        webhook url when the run completes or aborts.
+    --brightness        Add to brightness of raster tiles, -1 to 1.    DEFAULT:0
+    --gamma             Gamma correction of raster tiles.              DEFAULT:1
+    --single            Download only given z/x/y tile, no zooms and
+                        bbox needed.
+    --stdout            Write the single tile to stdout, diagnostics
+                        to stderr.
+    --coverage-report   Print share of downloaded tiles per zoom.
+    --coverage-grid     Draw downloaded and missing tiles of each zoom
+                        in the coverage report.
+    --zoom-weighted-rate  Multiply wait time by given factor for each
+                        zoom above the lowest one, e.g. 1.5. Lowers
+                        --rps alike if above 1.                        DEFAULT:1
+    --assert            Download z/x/y tile and compare it to reference
+                        file, given as z/x/y=path. Repeatable. Exits
+                        with non-zero status on mismatch.
+    --output            Directory to save the z/x/y tree in.           DEFAULT:.
+    --scheme            Y axis of the server's {y}: xyz or tms.        DEFAULT:xyz
+    --format            Extension of saved tiles: png, jpg, webp...
+                        DEFAULT: by Content-Type of the response, png
+    --retries           Retries of a tile after a network error, a 5xx
+                        or a 429 response.                             DEFAULT:3
+    --retry-backoff     Wait before the first retry, doubled for each
+                        following one, e.g. 2s.                        DEFAULT:500ms
+    --concurrency       Number of simultaneous downloads, each waiting
+                        --wait between its tiles.                      DEFAULT:1
+    --concurrency-per-zoom-auto
+                        Download zoom levels in turn, each with one
+                        download per 16 tiles, up to --concurrency.
+    --resume            Skip tiles saved already, e.g. to continue an
+                        interrupted download. Use --format if tiles
+                        are not png. Works with --mbtiles too.
+    --username          User name of HTTP Basic Auth. Credentials can
+                        also be given in url, user:pass@host.
+    --password          Password of HTTP Basic Auth.
+    --header            Header sent with each request, "Name: value",
+                        e.g. an API key. Repeatable.
+    --mbtiles           Write tiles into given MBTiles file instead of
+                        the z/x/y tree. An existing file is refused
+                        unless merging or resuming.
+    --merge-into-existing-mbtiles
+                        Add the tiles into an existing --mbtiles file,
+                        replacing those added again, its zoom range and
+                        bounds extended. Files of other schemas or
+                        tile formats are refused.
+    --dry-run           Print number of tiles to download per zoom
+                        and exit.
+    --max-tiles         Refuse to download more tiles, 0 for no limit. DEFAULT:1000000
+    --force             Download even if there are more than
+                        --max-tiles tiles.
+    --output-format     Format of the summary: text or json. With json,
+                        other reports are written to stderr.           DEFAULT:text
+    --failures-file     Write tiles which failed to given file, one
+                        z/x/y per line.
+    --tiles-file        Download tiles listed in given file, one z/x/y
+                        per line, e.g. --failures-file of an earlier
+                        run. No zooms and bbox needed.
+    --max-retry-after   Longest wait of a server's Retry-After header
+                        before retrying a 429 response.                DEFAULT:1m
+    --timeout           Timeout of a request, e.g. 10s or 2m.          DEFAULT:30s
+    --subdomains        Comma-separated subdomains rotated in the {s}
+                        placeholder of url.                            DEFAULT:a,b,c
+    --tile-size         Width and height of tiles in pixels, for WMS
+                        {width} and {height}.                          DEFAULT:256
+    --srs               EPSG code of {bbox}: 3857 for web mercator
+                        meters or 4326 for degrees (lon,lat).          DEFAULT:3857
+    --config            Read options from given YAML or JSON file of
+                        option names and values. Options given on the
+                        command line take precedence over the file.
+                        A layers list of name, url and optional format
+                        and mbtiles of each layer downloads them all,
+                        into subdirectories named after the layers or
+                        their MBTiles, instead of --url.
+    --skip-empty        Do not save empty tiles.
+    --skip-hash         Comma-separated MD5 checksums of blank tiles
+                        which are not saved, e.g. transparent ones.
+    --verify            Check that tiles are png, jpeg or gif images of
+                        --tile-size pixels, retry them otherwise.
+    --leaflet           Write index.html previewing the tiles with
+                        Leaflet into the output directory.
+    --log-level         error hides progress, debug logs url and status
+                        of each tile: error, info or debug.            DEFAULT:info
+    --name-template     Path of saved tiles with {z}, {x}, {y}, {r} and
+                        {ext}, e.g. "tile_{z}_{x}_{y}.{ext}".
+                                              DEFAULT:{z}/{x}/{y}{r}.{ext}
+    --no-decompress     Save gzip or deflate encoded tiles as served,
+                        without decompressing them.
+    --manifest          Write each attempted tile with its url, status,
+                        size and outcome to given CSV file.
+    --max-conns-per-host  Keep up to given connections to the server
+                        open for reuse, e.g. matching --concurrency.
+    --overwrite         Replace tiles saved already, which are kept and
+                        counted as skipped otherwise, without
+                        downloading them again.
+    --file-mode         Octal permissions of all files written, tiles,
+                        containers, reports and previews, directories
+                        get matching search bits.                      DEFAULT:0644
+    --wmts              Url is a WMTS endpoint, request tiles with KVP
+                        GetTile parameters, --format picking FORMAT.
+    --wmts-layer        WMTS layer to request.
+    --wmts-style        WMTS style of the layer.                       DEFAULT:default
+    --wmts-matrix-set   WMTS tile matrix set, zooms being its matrices.
+    --retina            Download high-DPI tiles, with @2x for {r} in
+                        url and saved names, e.g. y@2x.png, and twice
+                        --tile-size for {width} and {height}.
+    --checksums         Save md5 or sha256 digest of each tile next to
+                        it, e.g. y.png.sha256 for sha256sum -c.
+    --zip               Write tiles into given ZIP archive instead of
+                        the output directory.
+    --fail-fast         Abort the run at the first failed tile, except
+                        tiles not found (404).
+    --max-consecutive-failures  Abort the run after given failed tiles
+                        in a row, except tiles not found (404).
+    --tilejson          Take url, zooms, bbox and scheme not given from
+                        the TileJSON document at given url.
+    --world-files       Save a world file in web mercator next to each
+                        tile, e.g. y.pgw for y.png.
+    --vrt               Write a GDAL virtual raster stitching the tiles
+                        of each zoom, e.g. z12.vrt, into the output
+                        directory.
+    --verbose-errors    Show the start of the server's response with
+                        errors, e.g. an exception report of WMS.
+    --proxy             Send requests through the proxy at given url,
+                        e.g. http://proxy:8080, instead of HTTP_PROXY.
 Help Options:
     --help    Help. Prints usage in the stdout.
 `
 
 var options = tiles.Options{}
 
-// Tie command-line flags to the variables and
-// set default variables and usage messages.
+// Tie command-line flags to the options and
+// set default values and usage messages.
 func init() {
-	flag.StringVar(&options.URL, "url", "", "")
-	flag.Var(&options.Zooms, "zooms", "")
-	flag.Var(&options.Bbox, "bbox", "")
-	flag.IntVar(&options.WaitTime, "wait", 1000, "")
-	flag.BoolVar(&options.Help, "help", false, "")
+	options.RegisterFlags(flag.CommandLine)
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stdout, usageText)
 	}
 }
 
+// indexedWriter writes tiles with writer and
+// adds them to the index.
+type indexedWriter struct {
+	writer tiles.TileWriter
+	index  *tiles.Index
+}
+
+// Write writes the tile and adds it to the index.
+func (w indexedWriter) Write(tile *tiles.Tile) error {
+	if err := w.writer.Write(tile); err != nil {
+		return err
+	}
+	return w.index.Add(tile)
+}
+
+// writeToStdout downloads the tile and writes its content to
+// stdout, and the request's diagnostics to stderr.
+func writeToStdout(tileID wmsmercantile.TileID) {
+	tile, err := tiles.Get(context.Background(), tileID, options)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "%v %v %v %v bytes\n", tile.URL, tile.StatusCode, tile.ContentType, len(tile.Content))
+	if _, err := os.Stdout.Write(tile.Content); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run holds the state of a download run. The
+// state is shared by all download workers.
+type run struct {
+	jobs     tiles.JobStats
+	missing  []mercantile.TileID
+	callback *tileCallback
+	// writer writes the tiles, into one of dataURIs,
+	// store, mbtiles and zip if they are set, adding
+	// them to index if it is set.
+	writer   tiles.TileWriter
+	dataURIs *dataURIs
+	index    *tiles.Index
+	store    *tiles.ContentStore
+	mbtiles  *tiles.MBTiles
+	zip      *tiles.ZipArchive
+	manifest *manifest
+	failures *tiles.FailureReport
+	// Failures of each layer by name, with options.Layers.
+	layerFailures map[string]*tiles.FailureReport
+	// Downloaded tiles, collected for the coverage report.
+	present map[mercantile.TileID]bool
+	// Number and total size of saved tiles, and whether
+	// their average size has been checked.
+	saved       int
+	savedBytes  int
+	sizeChecked bool
+	// Tiles of the current download worth retrying.
+	failed []mercantile.TileID
+	// Number of tiles failed in a row, see checkFailures.
+	consecutiveFailures int
+	// File extension of the first saved tile.
+	extension string
+	// When progress was last written to options.ProgressFile.
+	progressWritten time.Time
+	// ctx is cancelled on SIGINT or SIGTERM, to stop
+	// starting new downloads and abort those in progress.
+	ctx context.Context
+	// mu guards the fields above, except
+	// jobs and failures, which guard themselves.
+	mu sync.Mutex
+}
+
+// writeProgress writes state of jobs to the progress file,
+// at most once a second unless forced. The file is written
+// outside r.mu, not to hold up the workers.
+func (r *run) writeProgress(force bool) {
+	if options.ProgressFile == "" {
+		return
+	}
+	r.mu.Lock()
+	due := force || time.Since(r.progressWritten) >= time.Second
+	if due {
+		r.progressWritten = time.Now()
+	}
+	r.mu.Unlock()
+	if !due {
+		return
+	}
+	if err := r.jobs.WriteProgress(options.ProgressFile, options.FileMode); err != nil {
+		logger.Warn("Writing progress failed", "error", err)
+	}
+}
+
+// download downloads and saves the tiles with tiles.DownloadTiles,
+// recording results in jobs, until the run is stopped. Returns the
+// failed tiles, except those the server does not have, which are
+// recorded as missing, and why the run was aborted if it was.
+// Interrupting the run is not an error.
+func (r *run) download(tileIDs []mercantile.TileID) ([]mercantile.TileID, error) {
+	r.failed = nil
+	_, err := tiles.DownloadTiles(r.ctx, libraryTileIDs(tileIDs), options, tiles.Hooks{
+		Jobs:   &r.jobs,
+		Writer: r.writer,
+		Done:   r.process,
+	})
+	// Commands of the saved tiles may still fail the run.
+	if r.callback != nil {
+		r.callback.Wait()
+		if err == nil {
+			err = r.callback.Err()
+		}
+	}
+	switch {
+	case errors.Is(err, tiles.ErrStorage):
+		return r.failed, fmt.Errorf("Aborting, tiles can not be saved: %v", err)
+	case r.interrupted():
+		return r.failed, nil
+	}
+	return r.failed, err
+}
+
+// downloadAll downloads the tiles with download, retrying
+// failed tiles after the main run, waiting longer before
+// each pass. Returns why the run was aborted if it was.
+func (r *run) downloadAll(tileIDs []mercantile.TileID) error {
+	failed, abortErr := r.download(tileIDs)
+	for pass := 1; pass <= options.RetryPasses && len(failed) > 0 && !r.interrupted() && abortErr == nil; pass++ {
+		if !r.jobs.JSON {
+			fmt.Println()
+		}
+		select {
+		case <-time.After(time.Duration(pass*options.RetryPassDelay) * time.Second):
+		case <-r.ctx.Done():
+			continue
+		}
+		for _, tileID := range failed {
+			r.jobs.AddFailed(tileID.Z, -1)
+		}
+		failed, abortErr = r.download(failed)
+	}
+	return abortErr
+}
+
+// downloadLayers downloads the tiles of each of options.Layers
+// in turn with downloadAll, setting options to those of the
+// layer (see tiles.Options.ForLayer) until done. The layers
+// share the jobs, and the rate limiter of options. Returns why
+// the run was aborted if it was.
+func (r *run) downloadLayers(tileIDs []mercantile.TileID) error {
+	all := options
+	defer func() {
+		options = all
+	}()
+	r.layerFailures = make(map[string]*tiles.FailureReport)
+	for _, layer := range all.Layers {
+		if r.interrupted() {
+			return nil
+		}
+		options = all.ForLayer(layer)
+		logger.Info("Downloading layer", "name", layer.Name)
+		if err := r.openLayer(); err != nil {
+			return err
+		}
+		r.failures = &tiles.FailureReport{}
+		r.layerFailures[layer.Name] = r.failures
+		err := r.downloadAll(tileIDs)
+		if r.mbtiles != nil {
+			if closeErr := r.mbtiles.Close(); err == nil {
+				err = closeErr
+			}
+			r.mbtiles = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openLayer sets the writer of the layer of options:
+// its MBTiles, or the z/x/y tree, created if needed.
+func (r *run) openLayer() error {
+	if options.MBTiles == "" {
+		r.writer = tiles.FileWriter{Options: options}
+		return tiles.CheckOutputDir(options.OutputDir, options.FileMode)
+	}
+	mbtiles, err := tiles.OpenMBTiles(options.MBTiles, options)
+	if err != nil {
+		return err
+	}
+	r.mbtiles = mbtiles
+	r.writer = mbtiles
+	return nil
+}
+
+// libraryTileIDs converts tile ids to those of the tiles package.
+func libraryTileIDs(tileIDs []mercantile.TileID) []wmsmercantile.TileID {
+	ids := make([]wmsmercantile.TileID, len(tileIDs))
+	for i, tileID := range tileIDs {
+		ids[i] = tiles.GetTileID(tileID.X, tileID.Y, tileID.Z)
+	}
+	return ids
+}
+
+// commandTileIDs converts tile ids of the tiles package.
+func commandTileIDs(ids []wmsmercantile.TileID) []mercantile.TileID {
+	tileIDs := make([]mercantile.TileID, len(ids))
+	for i, id := range ids {
+		tileIDs[i] = mercantile.TileID{X: id.X, Y: id.Y, Z: id.Z}
+	}
+	return tileIDs
+}
+
+// interrupted tells if the run has been stopped by a signal.
+func (r *run) interrupted() bool {
+	return r.ctx.Err() != nil
+}
+
+// close closes the outputs of the run.
+func (r *run) close() error {
+	if r.index != nil {
+		if err := r.index.Close(); err != nil {
+			return err
+		}
+	}
+	if r.store != nil {
+		if err := r.store.Close(); err != nil {
+			return err
+		}
+	}
+	if r.mbtiles != nil {
+		if err := r.mbtiles.Close(); err != nil {
+			return err
+		}
+	}
+	if r.zip != nil {
+		if err := r.zip.Close(); err != nil {
+			return err
+		}
+	}
+	if r.manifest != nil {
+		return r.manifest.Close()
+	}
+	return nil
+}
+
+// process records the outcome of a tile of download.
+// Tiles which failed and are worth retrying are kept
+// in r.failed. Returns an error if the run is to be
+// aborted (see checkFailures and checkAverageSize).
+func (r *run) process(result tiles.Result) error {
+	tileID := mercantile.TileID{X: result.TileID.X, Y: result.TileID.Y, Z: result.TileID.Z}
+	if logger.Enabled(context.Background(), slog.LevelInfo) {
+		r.jobs.ShowCurrentState()
+	}
+	r.writeProgress(false)
+
+	tile, err := result.Tile, result.Err
+	if tile != nil || err != nil {
+		logTile(tileID, tile, err)
+	}
+	switch {
+	case result.Skipped:
+		r.record(tileID, tile, "skipped", nil)
+		r.failures.Remove(result.TileID)
+		r.markPresent(tileID)
+	case err != nil:
+		r.record(tileID, tile, "failed", err)
+		url, _ := requested(tile, err)
+		r.failures.Add(result.TileID, url, err)
+		r.mu.Lock()
+		if errors.Is(err, tiles.ErrNotFound) {
+			r.missing = append(r.missing, tileID)
+		} else {
+			r.failed = append(r.failed, tileID)
+		}
+		r.mu.Unlock()
+		if !errors.Is(err, tiles.ErrNotFound) {
+			return r.checkFailures(true, err)
+		}
+	default:
+		r.mu.Lock()
+		r.saved++
+		r.savedBytes += len(tile.Content)
+		if r.extension == "" {
+			r.extension = path.Ext(tile.Name)
+		}
+		r.mu.Unlock()
+		if r.callback != nil {
+			if err := r.callback.Err(); err != nil {
+				return err
+			}
+			r.callback.Run(tile, tileID)
+		}
+		r.record(tileID, tile, "saved", nil)
+		r.failures.Remove(result.TileID)
+		r.markPresent(tileID)
+		r.checkFailures(false, nil)
+		return r.checkAverageSize()
+	}
+	return nil
+}
+
+// record adds the outcome of the tile to the manifest,
+// if there is one.
+func (r *run) record(tileID mercantile.TileID, tile *tiles.Tile, outcome string, err error) {
+	if r.manifest == nil {
+		return
+	}
+	if err := r.manifest.Add(tileID, tile, outcome, err); err != nil {
+		logger.Warn("Writing manifest failed", "error", err)
+	}
+}
+
+// markPresent records the tile as downloaded
+// if a coverage report was asked for.
+func (r *run) markPresent(tileID mercantile.TileID) {
+	if r.present != nil {
+		r.mu.Lock()
+		r.present[tileID] = true
+		r.mu.Unlock()
+	}
+}
+
+// notify posts the state of the run to options.NotifyURL.
+func (r *run) notify(status string, message string) {
+	if options.NotifyURL == "" {
+		return
+	}
+	if err := tiles.Notify(options.NotifyURL, status, message, &r.jobs, options); err != nil {
+		logger.Warn("Notifying failed", "error", err)
+	}
+}
+
+// checkFailures counts tiles failed in a row and returns an error
+// aborting the run when there are more of them than options allow.
+func (r *run) checkFailures(failed bool, err error) error {
+	limit := options.MaxConsecutiveFailures
+	if options.FailFast {
+		limit = 1
+	}
+	if limit == 0 {
+		return nil
+	}
+	r.mu.Lock()
+	if !failed {
+		r.consecutiveFailures = 0
+		r.mu.Unlock()
+		return nil
+	}
+	r.consecutiveFailures++
+	n := r.consecutiveFailures
+	r.mu.Unlock()
+	switch {
+	case n >= limit && n == 1:
+		return fmt.Errorf("Aborting at the first failed tile: %v. Check the url and credentials.", err)
+	case n >= limit:
+		return fmt.Errorf("Aborting after %v failed tiles in a row, the last one: %v. Check the url and credentials.", n, err)
+	}
+	return nil
+}
+
+// checkAverageSize returns an error aborting the run if the
+// average size of saved tiles is outside the range given in
+// options. The check is done once, after options.AvgSizeAfter
+// tiles.
+func (r *run) checkAverageSize() error {
+	r.mu.Lock()
+	if options.AvgSizeAfter == 0 || r.sizeChecked || r.saved < options.AvgSizeAfter {
+		r.mu.Unlock()
+		return nil
+	}
+	r.sizeChecked = true
+	saved, average := r.saved, r.savedBytes/r.saved
+	r.mu.Unlock()
+	if (options.MinAvgSize > 0 && average < options.MinAvgSize) ||
+		(options.MaxAvgSize > 0 && average > options.MaxAvgSize) {
+		return fmt.Errorf("Aborting, average size of %v tiles is %v bytes (min %v, max %v). Check the url, tiles may be error images.",
+			saved, average, options.MinAvgSize, options.MaxAvgSize)
+	}
+	return nil
+}
+
 func main() {
-  flag.Parse()
-  if err := options.ValidateOptions(); err != nil {
-	   log.Fatal(err)
+	flag.Parse()
+	if options.Config != "" {
+		if err := loadConfig(options.Config); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// After the config file, which may set the level.
+	if err := setLogLevel(options.LogLevel); err != nil {
+		log.Fatal(err)
+	}
+	if options.BboxFile != "" {
+		if err := options.ReadBboxFile(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// The TileJSON document is fetched through the proxy too.
+	if options.Proxy != "" {
+		transport, err := tiles.NewProxyTransport(options.Proxy, options.MaxConnsPerHost)
+		if err != nil {
+			log.Fatal(err)
+		}
+		options.Transport = transport
+	}
+	if options.TileJSON != "" {
+		if err := options.ReadTileJSON(context.Background()); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := options.ValidateOptions(); err != nil {
+		log.Fatal(err)
+	}
+
+	if options.WMTS {
+		options.URL = tiles.WMTSURL(options)
+	}
+
+	if options.UserAgentFile != "" {
+		if err := options.UserAgents.ReadFile(options.UserAgentFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if options.RequestTemplateFile != "" {
+		t, err := tiles.ParseRequestTemplate(options.RequestTemplateFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		options.RequestTemplate = t
+	}
+
+	if options.RPS > 0 {
+		options.RateLimiter = tiles.NewRateLimiter(options.RPS)
+	}
+
+	// Kept through the retry passes.
+	options.Session = tiles.NewSession()
+
+	if options.MaxConnsPerHost > 0 && options.Transport == nil {
+		options.Transport = tiles.NewTransport(options.MaxConnsPerHost)
+	}
+
+	if options.HTTPCache != "" {
+		options.Transport = tiles.NewCacheTransport(options.HTTPCache, options.StripQuery, options.Transport)
+	}
+
+	if len(options.Asserts) > 0 {
+		if failed := checkAssertions(options.Asserts); failed > 0 {
+			log.Fatalf("%v of %v assertions failed", failed, len(options.Asserts))
+		}
+		return
+	}
+
+	var tilesIds []mercantile.TileID
+	if options.Single != "" {
+		tileID, err := tiles.ParseTileID(options.Single)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if options.Stdout {
+			writeToStdout(tileID)
+			return
+		}
+		tilesIds = commandTileIDs([]wmsmercantile.TileID{tileID})
+	} else if options.TilesFile != "" {
+		tileIDs, err := tiles.ReadTileList(options.TilesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tilesIds = commandTileIDs(tileIDs)
+	} else {
+		tilesIds = mercantile.Tiles(
+			options.Bbox.Left,
+			options.Bbox.Bottom,
+			options.Bbox.Right,
+			options.Bbox.Top,
+			options.Zooms,
+		)
+	}
+	clipped := 0
+	if options.Area != nil {
+		all := len(tilesIds)
+		tilesIds = commandTileIDs(tiles.ClipToArea(libraryTileIDs(tilesIds), options.Area))
+		clipped = all - len(tilesIds)
+	}
+
+	if options.DumpGeoJSON != "" {
+		if err := writeGeoJSON(options.DumpGeoJSON, tilesIds); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if options.CheckSeams {
+		seams, err := checkSeams(tilesIds, options)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if seams > 0 {
+			log.Fatalf("Found %v suspicious seams, check the y axis scheme and zoom of the url", seams)
+		}
+		return
+	}
+
+	if options.CORSCheck {
+		checkCORS(tilesIds)
+		return
+	}
+
+	if options.Benchmark {
+		benchmark(tilesIds, options.BenchmarkSample)
+		return
+	}
+
+	if options.Sample > 0 && options.Sample < len(tilesIds) {
+		rand.Shuffle(len(tilesIds), func(i, j int) {
+			tilesIds[i], tilesIds[j] = tilesIds[j], tilesIds[i]
+		})
+		tilesIds = tilesIds[:options.Sample]
 	}
 
-  tilesIds := mercantile.Tiles(
-		options.Bbox.Left,
-		options.Bbox.Bottom,
-		options.Bbox.Right,
-		options.Bbox.Top,
-		options.Zooms,
-	)
+	if options.LimitPerZoom > 0 {
+		tilesIds = limitPerZoom(tilesIds, options.LimitPerZoom)
+	}
+
+	if options.DryRun {
+		showTileCounts(tilesIds, clipped)
+		return
+	}
+
+	// Each layer downloads all the tiles.
+	count := len(tilesIds)
+	if len(options.Layers) > 0 {
+		count *= len(options.Layers)
+	}
+	if options.MaxTiles > 0 && count > options.MaxTiles && !options.Force {
+		log.Fatalf("Refusing to download %v tiles, more than the limit of %v. Check zooms and bbox with --dry-run, raise --max-tiles or use --force.", count, options.MaxTiles)
+	}
+
+	if options.DataURIJSON == "" && options.ContentAddressed == "" && options.MBTiles == "" && options.Zip == "" && len(options.Layers) == 0 {
+		if err := tiles.CheckOutputDir(options.OutputDir, options.FileMode); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	r := run{
+		jobs:     tiles.JobStats{Start: time.Now(), All: count, JSON: options.OutputFormat == "json"},
+		failures: &tiles.FailureReport{},
+	}
+	if options.CoverageReport || options.CoverageGrid {
+		r.present = make(map[mercantile.TileID]bool)
+	}
+	r.writer = tiles.FileWriter{Options: options}
+	if options.DataURIJSON != "" {
+		r.dataURIs = newDataURIs()
+		r.writer = r.dataURIs
+	}
+	if options.IndexDB != "" {
+		index, err := tiles.OpenIndex(options.IndexDB, options.FileMode)
+		if err != nil {
+			log.Fatal(err)
+		}
+		r.index = index
+	}
+	if options.ContentAddressed != "" {
+		store, err := tiles.OpenContentStore(options.ContentAddressed, options.FileMode)
+		if err != nil {
+			log.Fatal(err)
+		}
+		r.store = store
+		r.writer = store
+	}
+	if options.MBTiles != "" {
+		mbtiles, err := tiles.OpenMBTiles(options.MBTiles, options)
+		if err != nil {
+			log.Fatal(err)
+		}
+		r.mbtiles = mbtiles
+		r.writer = mbtiles
+	}
+	if options.Manifest != "" {
+		m, err := createManifest(options.Manifest)
+		if err != nil {
+			log.Fatal(err)
+		}
+		r.manifest = m
+	}
+	if options.Zip != "" {
+		archive, err := tiles.CreateZipArchive(options.Zip, options)
+		if err != nil {
+			log.Fatal(err)
+		}
+		r.zip = archive
+		r.writer = archive
+	}
+	if r.index != nil {
+		r.writer = indexedWriter{writer: r.writer, index: r.index}
+	}
+	if options.OnTile != "" {
+		r.callback = newTileCallback(options.OnTile, options.OnTileJobs, options.OnTileFatal)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.ctx = ctx
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		// Another signal kills right away.
+		signal.Stop(signals)
+		logger.Info("Interrupted, cancelling downloads in progress")
+		cancel()
+	}()
+
+	var abortErr error
+	if len(options.Layers) > 0 {
+		abortErr = r.downloadLayers(tilesIds)
+	} else {
+		abortErr = r.downloadAll(tilesIds)
+	}
+
+	r.jobs.ShowSummary()
+	r.writeProgress(true)
+	// Keep stdout valid JSON with
+	// the summary in it only.
+	var reports io.Writer = os.Stdout
+	if r.jobs.JSON {
+		reports = os.Stderr
+	}
+	if len(options.Layers) > 0 {
+		for _, layer := range options.Layers {
+			if failures := r.layerFailures[layer.Name]; failures != nil && len(failures.Tiles()) > 0 {
+				fmt.Fprintf(reports, "Layer %v:\n", layer.Name)
+				failures.Show(reports)
+			}
+		}
+	} else {
+		r.failures.Show(reports)
+	}
+	if r.present != nil {
+		showCoverage(reports, tilesIds, r.present, options.CoverageGrid)
+	}
+	if r.store != nil {
+		r.store.ShowSummary(reports)
+	}
 
-  jobs := tiles.JobStats{Start: time.Now(), All: 0, Succeeded: 0, Failed: 0}
+	if r.dataURIs != nil {
+		if err := r.dataURIs.WriteFile(options.DataURIJSON, options.FileMode); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-  jobs.All = len(tilesIds)
+	if err := r.close(); err != nil {
+		log.Fatal(err)
+	}
 
-  for _, tileID := range tilesIds {
-    jobs.ShowCurrentState()
+	if options.Leaflet {
+		extension := r.extension
+		if extension == "" {
+			extension = tiles.Extension(options.Format, "")
+		}
+		if err := writeLeaflet(options.OutputDir, tilesIds, strings.ReplaceAll(options.NameTemplate, "{r}", options.RetinaSuffix()), extension, options.OutputLayout == "tms"); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-    tilesTileID := tiles.GetTileID(tileID.X, tileID.Y, tileID.Z)
+	if options.VRT {
+		extension := r.extension
+		if extension == "" {
+			extension = tiles.Extension(options.Format, "")
+		}
+		if err := writeVRTs(options.OutputDir, tilesIds, options, strings.TrimPrefix(extension, ".")); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-    tile, err := tiles.Get(tilesTileID, options)
-    if err != nil {
-      jobs.Failed++
-    } else {
-      err := tiles.Save(tile)
-      if err != nil {
-        jobs.Failed++
-      } else {
-        jobs.Succeeded++
-      }
-    }
+	if options.FailuresFile != "" {
+		var failedIDs []mercantile.TileID
+		urls := make(map[mercantile.TileID]string)
+		for _, tileID := range r.failures.Tiles() {
+			failedID := mercantile.TileID{X: tileID.X, Y: tileID.Y, Z: tileID.Z}
+			failedIDs = append(failedIDs, failedID)
+			urls[failedID] = r.failures.URL(tileID)
+		}
+		if err := writeTileList(options.FailuresFile, failedIDs, urls); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-    time.Sleep(time.Duration(options.WaitTime) * time.Millisecond)
-  }
+	if options.ReportMissing != "" {
+		if err := writeTileList(options.ReportMissing, r.missing, nil); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-  fmt.Sprintf("\n")
+	if r.callback != nil {
+		if failed := r.callback.Wait(); failed > 0 {
+			logger.Warn("Tile commands failed", "count", failed)
+		}
+	}
+	// The outputs are complete up to where
+	// the run was aborted or interrupted.
+	if abortErr != nil {
+		r.notify("aborted", abortErr.Error())
+		log.Fatal(abortErr)
+	}
+	if r.interrupted() {
+		r.notify("aborted", "Interrupted")
+		os.Exit(130)
+	}
+	r.notify("completed", "")
 }
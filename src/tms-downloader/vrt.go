@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"tms-downloader/mercantile"
+	"tms-downloader/tiles"
+)
+
+// vrtDataset is a GDAL virtual raster, see
+// https://gdal.org/drivers/raster/vrt.html.
+type vrtDataset struct {
+	XMLName      xml.Name  `xml:"VRTDataset"`
+	RasterXSize  int       `xml:"rasterXSize,attr"`
+	RasterYSize  int       `xml:"rasterYSize,attr"`
+	SRS          string    `xml:"SRS"`
+	GeoTransform string    `xml:"GeoTransform"`
+	Bands        []vrtBand `xml:"VRTRasterBand"`
+}
+
+// vrtBand is a band of vrtDataset, read from
+// a band of each tile.
+type vrtBand struct {
+	DataType    string      `xml:"dataType,attr"`
+	Band        int         `xml:"band,attr"`
+	ColorInterp string      `xml:"ColorInterp"`
+	Sources     []vrtSource `xml:"ComplexSource"`
+}
+
+// vrtSource places a band of a tile in vrtBand.
+type vrtSource struct {
+	Filename            vrtFilename `xml:"SourceFilename"`
+	SourceBand          int         `xml:"SourceBand"`
+	ScaleOffset         *int        `xml:"ScaleOffset,omitempty"`
+	ScaleRatio          *int        `xml:"ScaleRatio,omitempty"`
+	ColorTableComponent int         `xml:"ColorTableComponent,omitempty"`
+	SrcRect             vrtRect     `xml:"SrcRect"`
+	DstRect             vrtRect     `xml:"DstRect"`
+}
+
+// vrtFilename is the path of a tile, relative
+// to the virtual raster.
+type vrtFilename struct {
+	RelativeToVRT int    `xml:"relativeToVRT,attr"`
+	Name          string `xml:",chardata"`
+}
+
+// vrtRect is a rectangle of pixels.
+type vrtRect struct {
+	XOff  int `xml:"xOff,attr"`
+	YOff  int `xml:"yOff,attr"`
+	XSize int `xml:"xSize,attr"`
+	YSize int `xml:"ySize,attr"`
+}
+
+// vrtColors are the bands of a virtual raster, RGBA
+// whatever the color model of the tiles.
+var vrtColors = []string{"Red", "Green", "Blue", "Alpha"}
+
+// opaque makes a source give 255, for the alpha
+// band of tiles without one.
+var opaque, zero = 255, 0
+
+// writeVRTs writes z<zoom>.vrt into dir for each zoom level,
+// stitching the tiles saved as by options, in their extension.
+// Tiles which are missing or not PNG, JPEG or GIF images are
+// left out.
+func writeVRTs(dir string, tileIDs []mercantile.TileID, options tiles.Options, extension string) error {
+	options.Format = extension
+	zooms := make(map[int][]mercantile.TileID)
+	for _, tileID := range tileIDs {
+		zooms[tileID.Z] = append(zooms[tileID.Z], tileID)
+	}
+	for z, ids := range zooms {
+		if err := writeVRT(path.Join(dir, fmt.Sprintf("z%v.vrt", z)), ids, options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeVRT writes the virtual raster of tiles of a single zoom level.
+func writeVRT(filename string, tileIDs []mercantile.TileID, options tiles.Options) error {
+	sort.Slice(tileIDs, func(i, j int) bool {
+		if tileIDs[i].Y != tileIDs[j].Y {
+			return tileIDs[i].Y < tileIDs[j].Y
+		}
+		return tileIDs[i].X < tileIDs[j].X
+	})
+	minX, minY, maxX, maxY := tileIDs[0].X, tileIDs[0].Y, tileIDs[0].X, tileIDs[0].Y
+	for _, tileID := range tileIDs {
+		minX, maxX = minInt(minX, tileID.X), maxInt(maxX, tileID.X)
+		minY, maxY = minInt(minY, tileID.Y), maxInt(maxY, tileID.Y)
+	}
+
+	dataset := vrtDataset{SRS: "EPSG:3857"}
+	for i, colorInterp := range vrtColors {
+		dataset.Bands = append(dataset.Bands, vrtBand{DataType: "Byte", Band: i + 1, ColorInterp: colorInterp})
+	}
+	size := 0
+	for _, tileID := range tileIDs {
+		name := tiles.FilePath(tiles.GetTileID(tileID.X, tileID.Y, tileID.Z), options)
+		width, bands, err := tileBands(name)
+		if err != nil {
+			continue
+		}
+		if size == 0 {
+			size = width
+		}
+		rel, err := filepath.Rel(path.Dir(filename), name)
+		if err != nil {
+			return err
+		}
+		for i, source := range bands {
+			source.Filename = vrtFilename{RelativeToVRT: 1, Name: filepath.ToSlash(rel)}
+			source.SrcRect = vrtRect{XSize: size, YSize: size}
+			source.DstRect = vrtRect{XOff: (tileID.X - minX) * size, YOff: (tileID.Y - minY) * size, XSize: size, YSize: size}
+			dataset.Bands[i].Sources = append(dataset.Bands[i].Sources, source)
+		}
+	}
+	if size == 0 {
+		return nil
+	}
+
+	// The tiles are square, the geotransform follows
+	// from the upper left corner and the tile width.
+	ul := mercantile.XyBounds(mercantile.TileID{X: minX, Y: minY, Z: tileIDs[0].Z})
+	pixel := (ul.Right - ul.Left) / float64(size)
+	dataset.GeoTransform = fmt.Sprintf("%.12f, %.12f, 0, %.12f, 0, %.12f", ul.Left, pixel, ul.Top, -pixel)
+	dataset.RasterXSize = (maxX - minX + 1) * size
+	dataset.RasterYSize = (maxY - minY + 1) * size
+
+	content, err := xml.MarshalIndent(dataset, "", "  ")
+	if err != nil {
+		return err
+	}
+	return options.FileMode.WriteFile(filename, append(content, '\n'))
+}
+
+// tileBands returns the width of the saved tile image and
+// the sources of the red, green, blue and alpha bands of
+// the virtual raster in it, as GDAL reads the image.
+func tileBands(filename string) (int, []vrtSource, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+	config, format, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, nil, err
+	}
+	band := func(n int) vrtSource { return vrtSource{SourceBand: n} }
+	constant := vrtSource{SourceBand: 1, ScaleOffset: &opaque, ScaleRatio: &zero}
+	switch {
+	case format == "png":
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return 0, nil, err
+		}
+		// The color type in the IHDR chunk tells the bands,
+		// color.Model does not tell gray with alpha apart.
+		header := make([]byte, 26)
+		if _, err := io.ReadFull(file, header); err != nil {
+			return 0, nil, err
+		}
+		switch header[25] {
+		case 0:
+			return config.Width, []vrtSource{band(1), band(1), band(1), constant}, nil
+		case 2:
+			return config.Width, []vrtSource{band(1), band(2), band(3), constant}, nil
+		case 4:
+			return config.Width, []vrtSource{band(1), band(1), band(1), band(2)}, nil
+		case 6:
+			return config.Width, []vrtSource{band(1), band(2), band(3), band(4)}, nil
+		}
+	case config.ColorModel == color.GrayModel:
+		return config.Width, []vrtSource{band(1), band(1), band(1), constant}, nil
+	case format == "jpeg":
+		return config.Width, []vrtSource{band(1), band(2), band(3), constant}, nil
+	}
+	// Paletted images are expanded by their color table.
+	var sources []vrtSource
+	for i := range vrtColors {
+		sources = append(sources, vrtSource{SourceBand: 1, ColorTableComponent: i + 1})
+	}
+	return config.Width, sources, nil
+}